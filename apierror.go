@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// Error codes of the API responses. Clients match on the code, never on the message,
+// so a code must not change once released; its message is translated through the
+// "error.<code>" entry of the catalogs.
+const (
+	codeInvalidItemID             = "invalid_item_id"
+	codeItemNotFound              = "item_not_found"
+	codeBodyTooLarge              = "body_too_large"
+	codeInvalidJSON               = "invalid_json"
+	codeInvalidForm               = "invalid_form"
+	codeInvalidInput              = "invalid_input"
+	codeUnknownTheme              = "unknown_theme"
+	codeInvalidTenant             = "invalid_tenant"
+	codeUnknownTenant             = "unknown_tenant"
+	codeMissingToken              = "missing_token"
+	codeTenantMismatch            = "tenant_mismatch"
+	codeMalformedToken            = "malformed_token"
+	codeUnsupportedTokenAlgorithm = "unsupported_token_algorithm"
+	codeInvalidTokenSignature     = "invalid_token_signature"
+	codeExpiredToken              = "expired_token"
+	codeRateLimited               = "rate_limited"
+	codeStreamingUnsupported      = "streaming_unsupported"
+	codeAdminRequired             = "admin_required"
+	codeFieldNotFound             = "field_not_found"
+	codeDemoError                 = "demo_error"
+)
+
+// ErrorResponse provides a standardized JSON error response format.
+// This ensures consistent error reporting across all API endpoints.
+type ErrorResponse struct {
+	Code    string       `json:"code" description:"Stable machine-readable error code, e.g. item_not_found"`
+	Error   string       `json:"error" description:"Description of the error in the language of the request"`
+	Details []FieldError `json:"details,omitempty" description:"Rejected input fields, for invalid_input errors"`
+}
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field" description:"JSON name of the field"`
+	Code    string `json:"code" description:"Validation rule the value violates, e.g. required or maxlength"`
+	Message string `json:"message" description:"Description of the violation in the language of the request"`
+}
+
+// errorResponse builds the response body for code, translating its message for ctx.
+func errorResponse(ctx context.Context, code string, args ...any) ErrorResponse {
+	return ErrorResponse{Code: code, Error: tr(ctx).T("error."+code, args...)}
+}
+
+// jsonError responds with the error code and its translated message. It replaces
+// rc.JSONError, whose body has no code.
+func jsonError(rc *nova.ResponseContext, statusCode int, code string, args ...any) error {
+	return rc.JSON(statusCode, errorResponse(rc.Request().Context(), code, args...))
+}
+
+// writeJSONError writes the same body as jsonError for middleware that runs outside
+// a nova handler.
+func writeJSONError(w http.ResponseWriter, r *http.Request, statusCode int, code string, args ...any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(errorResponse(r.Context(), code, args...))
+}
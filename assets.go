@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/xlc-dev/nova/nova"
+)
+
+// Cache policies of static assets. Fingerprinted URLs change whenever the content does,
+// so browsers may keep them forever; logical URLs must be revalidated on every use.
+const (
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "no-cache"
+)
+
+// asset is a static file prepared for serving: fingerprinted and, for text formats,
+// precompressed once at startup.
+type asset struct {
+	// name is the logical name used in templates, e.g. "app.css".
+	name string
+	// path is the fingerprinted name served with immutable caching, e.g. "app.3f2a9c1b04d5e6f7.css".
+	path        string
+	contentType string
+	hash        string
+	raw         []byte
+	gzip        []byte
+	brotli      []byte
+}
+
+// assetStore holds all static assets by logical and fingerprinted name.
+type assetStore struct {
+	byName map[string]*asset
+	byPath map[string]*asset
+}
+
+// appAssets are the files of the embedded static directory plus the generated theme stylesheet.
+var appAssets = mustLoadAssets()
+
+// mustLoadAssets prepares the embedded assets. The files are compiled into the binary,
+// so a failure is a programming error.
+func mustLoadAssets() *assetStore {
+	staticFS, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	store := &assetStore{byName: make(map[string]*asset), byPath: make(map[string]*asset)}
+	err = fs.WalkDir(staticFS, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(staticFS, name)
+		if err != nil {
+			return err
+		}
+		store.add(name, data)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	store.add("themes.css", []byte(themeRootStyles()))
+	return store
+}
+
+// add fingerprints data under the logical name and prepares its compressed variants.
+func (s *assetStore) add(name string, data []byte) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:8])
+	ext := path.Ext(name)
+	a := &asset{
+		name:        name,
+		path:        strings.TrimSuffix(name, ext) + "." + hash + ext,
+		contentType: mime.TypeByExtension(ext),
+		hash:        hash,
+		raw:         data,
+	}
+	if a.contentType == "" {
+		a.contentType = http.DetectContentType(data)
+	}
+	if isCompressible(a.contentType) {
+		a.gzip = smallerThan(data, gzipBytes(data))
+		a.brotli = smallerThan(data, brotliBytes(data))
+	}
+	s.byName[name] = a
+	s.byPath[a.path] = a
+}
+
+// assetURL resolves a logical asset name to its fingerprinted URL. Unknown names are
+// returned as plain static URLs, so a typo shows up as a 404 rather than a panic.
+func assetURL(name string) string {
+	if a, ok := appAssets.byName[name]; ok {
+		return "/static/" + a.path
+	}
+	return "/static/" + name
+}
+
+// handleAsset serves a static asset by fingerprinted or logical name, in the best
+// encoding the client accepts.
+func handleAsset(rc *nova.ResponseContext) error {
+	w, r := rc.Writer(), rc.Request()
+	file := rc.URLParam("file")
+
+	cacheControl := immutableCacheControl
+	a, ok := appAssets.byPath[file]
+	if !ok {
+		a, ok = appAssets.byName[file]
+		cacheControl = revalidateCacheControl
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	body, etag := a.raw, a.hash
+	switch {
+	case a.brotli != nil && acceptsEncoding(r, "br"):
+		body, etag = a.brotli, a.hash+"-br"
+		w.Header().Set("Content-Encoding", "br")
+	case a.gzip != nil && acceptsEncoding(r, "gzip"):
+		body, etag = a.gzip, a.hash+"-gz"
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", strconv.Quote(etag))
+	if a.gzip != nil || a.brotli != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	// ServeContent answers conditional and range requests from the ETag
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+	return nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r allows encoding,
+// honouring q=0 as a refusal.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) && strings.TrimSpace(name) != "*" {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// isCompressible reports whether content of the given type benefits from compression.
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/javascript" ||
+		mediaType == "application/json" ||
+		mediaType == "image/svg+xml"
+}
+
+// smallerThan returns compressed, or nil if compressing did not make data smaller.
+func smallerThan(data, compressed []byte) []byte {
+	if len(compressed) >= len(data) {
+		return nil
+	}
+	return compressed
+}
+
+// gzipBytes compresses data with gzip at the best compression level.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// brotliBytes compresses data with brotli at the best compression level.
+func brotliBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	bw.Write(data)
+	bw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// cachedHeaders are the response headers stored with a cached body. All other headers,
+// such as the rate limit, CORS and CSP headers, are set by the middlewares in front of
+// the cache and belong to the current request.
+var cachedHeaders = []string{"Content-Type", "ETag", "Last-Modified"}
+
+// cacheRule is the lifetime of the cached responses of a route: they are served as
+// fresh for TTL, then served stale for up to Stale more while a new copy is rendered.
+type cacheRule struct {
+	TTL   time.Duration
+	Stale time.Duration
+}
+
+// parseCacheRoute parses a "pattern=ttl[+stale]" entry of cache.routes,
+// e.g. "/api/v1/items=30s+1m".
+func parseCacheRoute(s string) (string, cacheRule, error) {
+	pattern, durations, ok := strings.Cut(s, "=")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || !strings.HasPrefix(pattern, "/") {
+		return "", cacheRule{}, fmt.Errorf("expected \"pattern=ttl[+stale]\", got %q", s)
+	}
+	ttl, stale, _ := strings.Cut(durations, "+")
+	var rule cacheRule
+	var err error
+	if rule.TTL, err = time.ParseDuration(strings.TrimSpace(ttl)); err != nil || rule.TTL <= 0 {
+		return "", cacheRule{}, fmt.Errorf("invalid TTL %q for %s, expected a positive duration", ttl, pattern)
+	}
+	if stale != "" {
+		if rule.Stale, err = time.ParseDuration(strings.TrimSpace(stale)); err != nil || rule.Stale < 0 {
+			return "", cacheRule{}, fmt.Errorf("invalid stale-while-revalidate window %q for %s", stale, pattern)
+		}
+	}
+	return pattern, rule, nil
+}
+
+// cacheControl is the Cache-Control header of cacheable responses. The rule's TTL only
+// applies on the server, which drops entries when items change; clients revalidate every
+// time, since a copy they kept could not be purged and pages show the visitor's own state.
+const cacheControl = "no-cache"
+
+// cacheVary returns the Vary header of cacheable responses: the request headers that
+// cacheKey and the tenant resolution of tenant read. Accept-Language is added by
+// localeMiddleware.
+func cacheVary(tenant tenantSettings) string {
+	vary := []string{"Cookie", "Accept"}
+	if tenant.Header != "" {
+		vary = append(vary, tenant.Header)
+	}
+	if tenant.Secret != "" {
+		vary = append(vary, "Authorization")
+	}
+	return strings.Join(vary, ", ")
+}
+
+// cacheEntry is a rendered 200 response.
+type cacheEntry struct {
+	tenant string
+	header http.Header
+	body   []byte
+	// nonce is the CSP nonce rendered into body, replaced by the nonce of each request it serves.
+	nonce  string
+	stored time.Time
+	rule   cacheRule
+	// refreshing is set while a stale entry is rendered again in the background.
+	refreshing bool
+}
+
+// responseCache keeps rendered responses of the read endpoints in memory, keyed by
+// everything the handlers render them from, see cacheKey.
+type responseCache struct {
+	mu         sync.Mutex
+	rules      map[string]cacheRule
+	maxEntries int
+	entries    map[string]*cacheEntry
+	// generations counts the invalidations of every tenant, so a response rendered
+	// before an item changed is not stored after the change purged the cache.
+	generations map[string]uint64
+}
+
+// appResponseCache is the response cache of the application. It lives across configuration
+// reloads, which reconfigure and empty it, so the item hooks always reach the active cache.
+var appResponseCache = &responseCache{
+	entries:     make(map[string]*cacheEntry),
+	generations: make(map[string]uint64),
+}
+
+// configure applies settings, which have been validated, and drops every entry,
+// since a reload may change how the pages are rendered.
+func (c *responseCache) configure(settings cacheSettings) {
+	rules := make(map[string]cacheRule, len(settings.Routes))
+	for _, route := range settings.Routes {
+		if pattern, rule, err := parseCacheRoute(route); err == nil {
+			rules[pattern] = rule
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = rules
+	c.maxEntries = settings.MaxEntries
+	clear(c.entries)
+}
+
+// rule returns the cache rule of a route pattern.
+func (c *responseCache) rule(pattern string) (cacheRule, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rule, ok := c.rules[pattern]
+	return rule, ok
+}
+
+// invalidate drops the cached responses of tenant. It is registered with onItemsChanged.
+func (c *responseCache) invalidate(tenant string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[tenant]++
+	for key, e := range c.entries {
+		if e.tenant == tenant {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// generation returns the invalidation count of tenant, taken before rendering a response.
+func (c *responseCache) generation(tenant string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[tenant]
+}
+
+// get returns the entry stored under key and how old it is. Entries past their stale
+// window are dropped. revalidate reports whether the caller must render the entry
+// again; it is true for exactly one caller per stale entry.
+func (c *responseCache) get(key string, now time.Time) (e *cacheEntry, age time.Duration, revalidate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	age = now.Sub(e.stored)
+	switch {
+	case age < e.rule.TTL:
+		return e, age, false
+	case age < e.rule.TTL+e.rule.Stale:
+		revalidate = !e.refreshing
+		e.refreshing = true
+		return e, age, revalidate
+	}
+	delete(c.entries, key)
+	return nil, 0, false
+}
+
+// put stores e under key unless the tenant's items changed after generation was taken.
+// When the cache is full, expired entries are dropped first, then the oldest ones.
+func (c *responseCache) put(key string, e *cacheEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[e.tenant] != generation || c.maxEntries <= 0 {
+		return
+	}
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		for k, old := range c.entries {
+			if e.stored.Sub(old.stored) >= old.rule.TTL+old.rule.Stale {
+				delete(c.entries, k)
+			}
+		}
+		for len(c.entries) >= c.maxEntries {
+			oldest := ""
+			for k, old := range c.entries {
+				if oldest == "" || old.stored.Before(c.entries[oldest].stored) {
+					oldest = k
+				}
+			}
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = e
+}
+
+// release clears the refreshing mark of the entry under key after a failed revalidation,
+// so a later request tries again.
+func (c *responseCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.refreshing = false
+	}
+}
+
+// Hooks run after items of a tenant have been created, updated or deleted.
+var (
+	itemHooksMu sync.Mutex
+	itemHooks   []func(tenant string)
+)
+
+// onItemsChanged registers a hook that runs whenever the items of a tenant change.
+func onItemsChanged(hook func(tenant string)) {
+	itemHooksMu.Lock()
+	defer itemHooksMu.Unlock()
+	itemHooks = append(itemHooks, hook)
+}
+
+// notifyItemsChanged runs the hooks registered with onItemsChanged. Handlers call it
+// after releasing mu.
+func notifyItemsChanged(tenant string) {
+	itemHooksMu.Lock()
+	hooks := slices.Clone(itemHooks)
+	itemHooksMu.Unlock()
+
+	for _, hook := range hooks {
+		hook(tenant)
+	}
+}
+
+// cacheMiddleware serves GET requests of the routes in settings.Routes from
+// appResponseCache. Responses are marked with X-Cache (HIT, STALE or MISS), and with
+// Age when served from the cache. Stale responses are served while the route is
+// rendered again in the background. Requests carrying a flash message, and responses
+// that set cookies or forbid caching, bypass the cache. tenant is needed for the Vary
+// header, see cacheVary.
+func cacheMiddleware(settings cacheSettings, tenant tenantSettings) nova.Middleware {
+	appResponseCache.configure(settings)
+	vary := cacheVary(tenant)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rule, ok := appResponseCache.rule(knownRoutes.lookup(r.URL.Path))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// The flash message is shown once, so the page showing it is never stored or served
+			if _, err := r.Cookie(flashCookie); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := cacheKey(r)
+			if e, age, revalidate := appResponseCache.get(key, time.Now()); e != nil {
+				status := "HIT"
+				if age >= e.rule.TTL {
+					status = "STALE"
+				}
+				if revalidate {
+					go refreshCacheEntry(next, r, key, rule)
+				}
+				serveCached(w, r, e, age, status, vary)
+				return
+			}
+
+			tenant := tenantFrom(r.Context())
+			generation := appResponseCache.generation(tenant)
+			rec := &cacheRecorder{ResponseWriter: w, rule: rule, vary: vary, cookies: len(w.Header().Values("Set-Cookie"))}
+			w.Header().Set("X-Cache", "MISS")
+			next.ServeHTTP(rec, r)
+			if e := rec.entry(r, tenant); e != nil {
+				appResponseCache.put(key, e, generation)
+			}
+		})
+	}
+}
+
+// cacheKey identifies the variant of a response: the tenant, the path with its sorted
+// query string, the negotiated content type, the language and the theme.
+func cacheKey(r *http.Request) string {
+	contentType := "text/html"
+	if strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		contentType = "application/json"
+	}
+	target := r.URL.Path
+	if query := r.URL.Query(); len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return strings.Join([]string{
+		tenantFrom(r.Context()),
+		target,
+		contentType,
+		tr(r.Context()).Tag,
+		selectedTheme(r),
+	}, "\x00")
+}
+
+// serveCached writes a cached response, giving it the CSP nonce of the current request.
+func serveCached(w http.ResponseWriter, r *http.Request, e *cacheEntry, age time.Duration, status, vary string) {
+	h := w.Header()
+	for name, values := range e.header {
+		h[name] = slices.Clone(values)
+	}
+	h.Set("Cache-Control", cacheControl)
+	h.Add("Vary", vary)
+	h.Set("Age", strconv.Itoa(int(age.Seconds())))
+	h.Set("X-Cache", status)
+
+	body := e.body
+	if nonce := cspNonce(r.Context()); e.nonce != "" && nonce != e.nonce {
+		body = bytes.ReplaceAll(body, []byte(e.nonce), []byte(nonce))
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// refreshCacheEntry renders a stale entry again for a copy of r that outlives the
+// request, and stores the result in its place.
+func refreshCacheEntry(next http.Handler, r *http.Request, key string, rule cacheRule) {
+	ctx := context.WithoutCancel(r.Context())
+	tenant := tenantFrom(ctx)
+	generation := appResponseCache.generation(tenant)
+
+	rec := &cacheRecorder{ResponseWriter: &discardWriter{header: make(http.Header)}, rule: rule}
+	req := r.Clone(ctx)
+	next.ServeHTTP(rec, req)
+	if e := rec.entry(req, tenant); e != nil {
+		appResponseCache.put(key, e, generation)
+		return
+	}
+	loggerFrom(ctx).Debug("Cached response not refreshed", "status", rec.status)
+	appResponseCache.release(key)
+}
+
+// cacheRecorder passes a response through while keeping a copy of its body,
+// and marks cacheable responses with the Cache-Control and Vary headers.
+type cacheRecorder struct {
+	http.ResponseWriter
+	rule cacheRule
+	vary string
+	// cookies is the number of Set-Cookie headers set before the handler ran.
+	cookies int
+
+	status int
+	body   bytes.Buffer
+	// bypass is set when the handler set a cookie or its own Cache-Control.
+	bypass bool
+}
+
+// WriteHeader decides whether the response is cacheable, which needs its final headers.
+func (w *cacheRecorder) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+		h := w.Header()
+		w.bypass = len(h.Values("Set-Cookie")) > w.cookies || h.Get("Cache-Control") != ""
+		if statusCode == http.StatusOK && !w.bypass {
+			h.Set("Cache-Control", cacheControl)
+			h.Add("Vary", w.vary)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records b and passes it through.
+func (w *cacheRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status == http.StatusOK && !w.bypass {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through, so streamed responses keep streaming.
+func (w *cacheRecorder) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *cacheRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// entry returns the recorded response as a cache entry for r, or nil if it must not be cached.
+func (w *cacheRecorder) entry(r *http.Request, tenant string) *cacheEntry {
+	if w.status != http.StatusOK || w.bypass {
+		return nil
+	}
+	header := make(http.Header, len(cachedHeaders))
+	for _, name := range cachedHeaders {
+		if v := w.Header().Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	return &cacheEntry{
+		tenant: tenant,
+		header: header,
+		body:   bytes.Clone(w.body.Bytes()),
+		nonce:  cspNonce(r.Context()),
+		stored: time.Now(),
+		rule:   w.rule,
+	}
+}
+
+// discardWriter is the response writer of background revalidations, which have no client.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
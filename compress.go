@@ -0,0 +1,253 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/xlc-dev/nova/nova"
+)
+
+// brotliDynamicLevel trades compression ratio for speed, since dynamic responses are
+// compressed on every request; static assets are precompressed at the best level instead.
+const brotliDynamicLevel = 4
+
+// compressionEncoder is an encoder that can be reset onto another writer and reused.
+type compressionEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressionEncoders pools the encoders of every supported content coding by name.
+// deflate is the zlib format, as HTTP defines it.
+var compressionEncoders = map[string]*sync.Pool{
+	"br": {New: func() any { return brotli.NewWriterLevel(io.Discard, brotliDynamicLevel) }},
+	"gzip": {New: func() any {
+		zw, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return zw
+	}},
+	"deflate": {New: func() any {
+		zw, _ := zlib.NewWriterLevel(io.Discard, zlib.DefaultCompression)
+		return zw
+	}},
+}
+
+// compressionMiddleware compresses response bodies in the encoding the client prefers
+// among settings.Encodings. Only the allowed content types are compressed, and only once
+// the body reaches settings.MinSize; responses that already have a Content-Encoding, such
+// as the precompressed static assets, pass through untouched.
+func compressionMiddleware(settings compressionSettings) nova.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				settings:       settings,
+				encoding:       preferredEncoding(r.Header.Get("Accept-Encoding"), settings.Encodings),
+			}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// preferredEncoding returns the encoding of supported with the highest weight in an
+// Accept-Encoding header, or "" when the client accepts none of them. Ties go to the
+// encoding listed first in supported, and "*" matches encodings not named explicitly.
+func preferredEncoding(header string, supported []string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if q, ok := qValue(params); ok {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := weights[enc]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// qValue returns the weight given by the q parameter among the ";"-separated params of
+// an Accept-Encoding or Accept-Language entry, 1 without one. Weights outside 0 to 1
+// are invalid.
+func qValue(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return q, err == nil && q >= 0 && q <= 1
+	}
+	return 1, true
+}
+
+// compressWriter holds back the status and the first MinSize bytes of a response until
+// it knows whether the body is worth compressing, then either compresses everything or
+// passes everything through.
+type compressWriter struct {
+	http.ResponseWriter
+	settings compressionSettings
+	encoding string // negotiated encoding, "" when the client accepts none
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder compressionEncoder
+}
+
+// WriteHeader records the status; it is sent once the encoding is decided.
+func (w *compressWriter) WriteHeader(statusCode int) {
+	switch {
+	case w.decided:
+		w.ResponseWriter.WriteHeader(statusCode)
+	case statusCode >= 100 && statusCode < 200:
+		// Informational responses are sent right away and do not end the header phase
+		w.ResponseWriter.WriteHeader(statusCode)
+	case w.status == 0:
+		w.status = statusCode
+	}
+}
+
+// Write buffers b until the body reaches the minimum size, then writes it compressed.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		if !w.compressible() {
+			w.start(false)
+		} else if len(w.buf)+len(b) < w.settings.MinSize {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		} else {
+			w.start(true)
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// compressible reports whether the response may be compressed, judging by its status
+// and headers. Bodies below the minimum size are left to Write and Close.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if w.status < 200 || w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.settings.MinSize {
+		return false
+	}
+	return w.allowedType()
+}
+
+// allowedType reports whether the Content-Type of the response is in the allow-list.
+// Entries ending in "/*" match every subtype.
+func (w *compressWriter) allowedType() bool {
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(w.settings.ContentTypes, func(allowed string) bool {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			return strings.HasPrefix(mediaType, prefix+"/")
+		}
+		return mediaType == allowed
+	})
+}
+
+// start sends the header, compressed if compress is true and the client accepts an
+// encoding, followed by the buffered body.
+func (w *compressWriter) start(compress bool) {
+	w.decided = true
+	h := w.Header()
+	// Caches must keep the variants apart whenever the content type could be compressed,
+	// including the uncompressed variant for clients that do not accept any encoding
+	if h.Get("Content-Encoding") == "" && w.allowedType() {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compress && w.encoding != "" {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// The compressed body differs byte for byte, so a strong validator would be wrong
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.encoder = compressionEncoders[w.encoding].Get().(compressionEncoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		if w.encoder != nil {
+			w.encoder.Write(w.buf)
+		} else {
+			w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+	}
+}
+
+// Flush sends what has been written so far, compressing it if the response qualifies,
+// and flushes the underlying writer.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(w.compressible())
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the response: bodies that stayed below the minimum size are sent
+// uncompressed, and the encoder is flushed and returned to its pool.
+func (w *compressWriter) Close() error {
+	if !w.decided && w.status != 0 {
+		w.start(false)
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(io.Discard)
+	compressionEncoders[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestPreferredEncoding(t *testing.T) {
+	supported := []string{"br", "gzip", "deflate"}
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "empty", header: "", want: ""},
+		{name: "identity only", header: "identity", want: ""},
+		{name: "single", header: "gzip", want: "gzip"},
+		{name: "ties go to supported order", header: "gzip, deflate, br", want: "br"},
+		{name: "case and spaces", header: " GZip ,  Deflate ", want: "gzip"},
+		{name: "highest weight", header: "br;q=0.5, gzip;q=0.8, deflate;q=0.1", want: "gzip"},
+		{name: "weight with spaces", header: "br ; q = 0.2, gzip ; q=0.9", want: "gzip"},
+		{name: "uppercase q", header: "br;Q=0, gzip", want: "gzip"},
+		{name: "q after other params", header: "br;level=5;q=0, gzip", want: "gzip"},
+		{name: "refused", header: "gzip;q=0", want: ""},
+		{name: "wildcard", header: "*", want: "br"},
+		{name: "wildcard with explicit refusal", header: "*;q=0.5, br;q=0", want: "gzip"},
+		{name: "explicit beats wildcard", header: "*;q=0.1, deflate", want: "deflate"},
+		{name: "wildcard refused", header: "*;q=0", want: ""},
+		{name: "invalid weight ignored", header: "br;q=abc, gzip;q=0.5", want: "gzip"},
+		{name: "weight above one ignored", header: "br;q=2, gzip;q=0.5", want: "gzip"},
+		{name: "infinite weight ignored", header: "br;q=inf, gzip;q=0.5", want: "gzip"},
+		{name: "unsupported only", header: "zstd, compress", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preferredEncoding(tt.header, supported); got != tt.want {
+				t.Errorf("preferredEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// envPrefix is prepended to the upper-cased config key to form its environment variable,
+// e.g. "cors.allowed_origins" is overridden by NOVA_CORS_ALLOWED_ORIGINS.
+const envPrefix = "NOVA_"
+
+// appConfig holds every setting of the application. Each leaf field is addressed by
+// the dotted path of its `config` tags; fields with a `flag` tag can also be set on the
+// command line, in which case the flag's Default is the field's default value.
+type appConfig struct {
+	Host            string        `config:"host" flag:"host"`
+	Port            int           `config:"port" flag:"port"`
+	Verbose         bool          `config:"verbose" flag:"verbose"`
+	Watch           bool          `config:"watch" flag:"watch"`
+	Extensions      []string      `config:"extensions" flag:"extensions"`
+	LogFormat       string        `config:"log_format" flag:"log_format"`
+	LogLevel        string        `config:"log_level" flag:"log_level"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" flag:"shutdown_timeout"`
+
+	Tenant        tenantSettings        `config:"tenant"`
+	RateLimit     rateLimitSettings     `config:"rate_limit"`
+	RequestID     requestIDSettings     `config:"request_id"`
+	RequestLog    requestLogSettings    `config:"request_log"`
+	TrailingSlash trailingSlashSettings `config:"trailing_slash"`
+	CORS          corsSettings          `config:"cors"`
+	Security      securitySettings      `config:"security"`
+	Session       sessionSettings       `config:"session"`
+	Theme         themeSettings         `config:"theme"`
+	CSP           cspSettings           `config:"csp"`
+	Locale        localeSettings        `config:"locale"`
+	Compression   compressionSettings   `config:"compression"`
+	Cache         cacheSettings         `config:"cache"`
+	Admin         adminSettings         `config:"admin"`
+
+	// API and Pages override the CORS and security header policy for the JSON API
+	// and the HTML pages. Keys they leave unset inherit the top-level value.
+	API   routeGroupSettings `config:"api"`
+	Pages routeGroupSettings `config:"pages"`
+}
+
+// tenantSettings configures tenant resolution, see tenantConfig.
+type tenantSettings struct {
+	Header  string   `config:"header" flag:"tenant_header"`
+	Domain  string   `config:"domain" flag:"tenant_domain"`
+	Secret  string   `config:"secret" flag:"tenant_secret"`
+	Allowed []string `config:"allowed" flag:"tenants"`
+}
+
+// rateLimitSettings configures the default client quota, see rateLimitConfig.
+type rateLimitSettings struct {
+	RequestsPerMinute int      `config:"requests_per_minute" flag:"rate_limit"`
+	TrustedProxies    []string `config:"trusted_proxies" flag:"trusted_proxies"`
+	// APIKeys are the known X-API-Key values, each limited on its own.
+	APIKeys []string `config:"api_keys"`
+}
+
+// requestIDSettings configures nova.RequestIDMiddleware.
+type requestIDSettings struct {
+	Enabled bool   `config:"enabled"`
+	Header  string `config:"header"`
+}
+
+// requestLogSettings configures requestLogMiddleware.
+type requestLogSettings struct {
+	Enabled          bool `config:"enabled"`
+	IncludeRequestID bool `config:"include_request_id"`
+}
+
+// trailingSlashSettings configures nova.TrailingSlashRedirectMiddleware.
+type trailingSlashSettings struct {
+	Enabled      bool `config:"enabled"`
+	AddSlash     bool `config:"add_slash"`
+	RedirectCode int  `config:"redirect_code"`
+}
+
+// sessionSettings configures the cookies the HTML pages keep across requests.
+type sessionSettings struct {
+	// Secret signs flash message cookies; a random key is used when it is empty.
+	Secret string `config:"secret"`
+}
+
+// themeSettings configures the themes of the HTML pages, see themeSet.
+type themeSettings struct {
+	// Default is the theme of visitors who have not picked one: auto or a theme name.
+	Default string              `config:"default"`
+	Custom  customThemeSettings `config:"custom"`
+}
+
+// customThemeSettings registers an additional theme. Tokens are "token=value" pairs
+// overriding the tokens of the Base theme, e.g. "primary-color=#00bcd4".
+type customThemeSettings struct {
+	Name   string   `config:"name"`
+	Label  string   `config:"label"`
+	Base   string   `config:"base"`
+	Tokens []string `config:"tokens"`
+}
+
+// cspSettings configures the Content-Security-Policy of the HTML pages, see cspMiddleware.
+type cspSettings struct {
+	Enabled bool `config:"enabled"`
+	// ReportOnly reports violations without blocking anything, for trying out the policy.
+	ReportOnly bool `config:"report_only"`
+	// ReportURI receives violation reports; empty disables reporting.
+	ReportURI string `config:"report_uri"`
+}
+
+// localeSettings configures the languages of the HTML pages, see localeMiddleware.
+type localeSettings struct {
+	// Default is the language of visitors whose browser accepts none of the catalogs.
+	Default string `config:"default"`
+}
+
+// compressionSettings configures compressionMiddleware.
+type compressionSettings struct {
+	Enabled bool `config:"enabled"`
+	// MinSize is the smallest body in bytes that is compressed; smaller bodies gain too little.
+	MinSize int `config:"min_size"`
+	// Encodings are the offered content codings, br, gzip and deflate, in order of preference.
+	Encodings []string `config:"encodings"`
+	// ContentTypes are the media types that are compressed; "text/*" matches all text types.
+	ContentTypes []string `config:"content_types"`
+}
+
+// cacheSettings configures cacheMiddleware.
+type cacheSettings struct {
+	Enabled bool `config:"enabled"`
+	// MaxEntries bounds the number of cached responses; the oldest are dropped first.
+	MaxEntries int `config:"max_entries"`
+	// Routes are "pattern=ttl[+stale]" entries: GET responses of the route pattern are
+	// cached for ttl and then served stale for up to stale while they are rendered again.
+	Routes []string `config:"routes"`
+}
+
+// adminSettings configures access to the admin API, see adminMiddleware.
+type adminSettings struct {
+	// Secret must be sent in the X-Admin-Key header to manage custom fields; the
+	// admin API is closed when it is empty.
+	Secret string `config:"secret"`
+}
+
+// routeGroupSettings is the middleware policy of a group of routes.
+type routeGroupSettings struct {
+	CORS     corsSettings     `config:"cors"`
+	Security securitySettings `config:"security"`
+}
+
+// corsSettings mirrors nova.CORSConfig.
+type corsSettings struct {
+	Enabled          bool     `config:"enabled"`
+	AllowedOrigins   []string `config:"allowed_origins"`
+	AllowedMethods   []string `config:"allowed_methods"`
+	AllowedHeaders   []string `config:"allowed_headers"`
+	ExposedHeaders   []string `config:"exposed_headers"`
+	AllowCredentials bool     `config:"allow_credentials"`
+	MaxAgeSeconds    int      `config:"max_age_seconds"`
+}
+
+// securitySettings mirrors nova.SecurityHeadersConfig.
+type securitySettings struct {
+	Enabled               bool   `config:"enabled"`
+	ContentTypeOptions    string `config:"content_type_options"`
+	FrameOptions          string `config:"frame_options"`
+	ReferrerPolicy        string `config:"referrer_policy"`
+	HSTSMaxAgeSeconds     int    `config:"hsts_max_age_seconds"`
+	HSTSIncludeSubdomains bool   `config:"hsts_include_subdomains"`
+	HSTSPreload           bool   `config:"hsts_preload"`
+}
+
+// defaultConfig returns the defaults of all settings that have no command-line flag.
+// Defaults of flag-backed settings are taken from the flag definitions in main.
+func defaultConfig() *appConfig {
+	return &appConfig{
+		RequestID:     requestIDSettings{Enabled: true, Header: "X-Request-ID"},
+		RequestLog:    requestLogSettings{Enabled: true, IncludeRequestID: true},
+		TrailingSlash: trailingSlashSettings{Enabled: true, RedirectCode: http.StatusMovedPermanently},
+		Theme:         themeSettings{Default: themeAuto, Custom: customThemeSettings{Base: "dark"}},
+		CSP:           cspSettings{Enabled: true, ReportURI: cspReportPath},
+		Locale:        localeSettings{Default: fallbackLocale},
+		Compression: compressionSettings{
+			Enabled:   true,
+			MinSize:   1024,
+			Encodings: []string{"br", "gzip", "deflate"},
+			ContentTypes: []string{
+				"text/html",
+				"text/plain",
+				"text/css",
+				"application/json",
+				"application/problem+json",
+				"application/javascript",
+				"image/svg+xml",
+			},
+		},
+		Cache: cacheSettings{
+			Enabled:    true,
+			MaxEntries: 1000,
+			Routes: []string{
+				"/=5m+10m",
+				"/items=30s+1m",
+				"/items/{itemId}=30s+1m",
+				"/api/v1/items=30s+1m",
+				"/api/v1/items/{itemId}=30s+1m",
+			},
+		},
+		CORS: corsSettings{
+			Enabled:        true,
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Request-ID", "X-Tenant-ID", adminHeader},
+			ExposedHeaders: []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+			MaxAgeSeconds:  86400, // 24 hours
+		},
+		Security: securitySettings{
+			Enabled:               true,
+			ContentTypeOptions:    "nosniff",
+			FrameOptions:          "DENY",
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+			HSTSMaxAgeSeconds:     31536000, // 1 year
+			HSTSIncludeSubdomains: true,
+		},
+	}
+}
+
+// configField is a settable leaf of appConfig.
+type configField struct {
+	key   string
+	flag  string
+	value reflect.Value
+}
+
+// configError reports a problem with a single setting, naming the key and where its value came from.
+type configError struct {
+	Key    string
+	Source string
+	Msg    string
+}
+
+// Error formats the error as "key (source): message".
+func (e *configError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("config: %s: %s", e.Key, e.Msg)
+	}
+	return fmt.Sprintf("config: %s (%s): %s", e.Key, e.Source, e.Msg)
+}
+
+// loadedConfig is a merged configuration together with the source of every value.
+type loadedConfig struct {
+	*appConfig
+	// sources maps each key to where its final value came from, e.g. "flag --port".
+	sources map[string]string
+	// path is the configuration file that was read, if any.
+	path string
+}
+
+// loadConfig builds the configuration with the precedence flags > environment > file > defaults.
+// The file is taken from --config or NOVA_CONFIG. All problems are collected and returned
+// together, each naming the offending key.
+func loadConfig(ctx *nova.Context, args []string) (*loadedConfig, error) {
+	cfg := &loadedConfig{appConfig: defaultConfig(), sources: make(map[string]string)}
+	fields := cfg.fields()
+	byKey := make(map[string]configField, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+		cfg.sources[f.key] = "default"
+	}
+
+	setFlags := explicitFlags(ctx.CLI, args)
+	var errs []error
+
+	// Defaults of flag-backed settings come from the flag definitions.
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		if err := setConfigValue(f.value, flagValue(ctx, f)); err != nil {
+			errs = append(errs, &configError{Key: f.key, Source: "default", Msg: err.Error()})
+		}
+	}
+
+	path := ctx.String("config")
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		cfg.path = path
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := values[key]
+			source := filepath.Base(path)
+			if v.line > 0 {
+				source = fmt.Sprintf("%s:%d", source, v.line)
+			}
+			f, ok := byKey[key]
+			if !ok {
+				errs = append(errs, &configError{Key: key, Source: source, Msg: "unknown key" + suggestKey(key, byKey)})
+				continue
+			}
+			if err := setConfigValue(f.value, v.value); err != nil {
+				errs = append(errs, &configError{Key: key, Source: source, Msg: err.Error()})
+				continue
+			}
+			cfg.sources[key] = source
+		}
+	}
+
+	for _, f := range fields {
+		name := envName(f.key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setConfigValue(f.value, raw); err != nil {
+			errs = append(errs, &configError{Key: f.key, Source: "env " + name, Msg: err.Error()})
+			continue
+		}
+		cfg.sources[f.key] = "env " + name
+	}
+
+	for _, f := range fields {
+		if f.flag == "" || !setFlags[f.flag] {
+			continue
+		}
+		if err := setConfigValue(f.value, flagValue(ctx, f)); err != nil {
+			errs = append(errs, &configError{Key: f.key, Source: "flag --" + f.flag, Msg: err.Error()})
+			continue
+		}
+		cfg.sources[f.key] = "flag --" + f.flag
+	}
+
+	cfg.inheritGroupSettings(fields)
+
+	if len(errs) == 0 {
+		errs = cfg.validate()
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// fields walks appConfig and returns all leaf settings in declaration order.
+func (c *appConfig) fields() []configField {
+	var fields []configField
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := sf.Tag.Get("config")
+			if key == "" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			fv := v.Field(i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(fv, key)
+				continue
+			}
+			fields = append(fields, configField{key: key, flag: sf.Tag.Get("flag"), value: fv})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return fields
+}
+
+// groupPrefixes are the config sections that override top-level settings for a route group.
+var groupPrefixes = []string{"api.", "pages."}
+
+// inheritGroupSettings copies every top-level value into the route group settings
+// that were not set explicitly, so "api.cors.allowed_origins" defaults to "cors.allowed_origins".
+func (c *loadedConfig) inheritGroupSettings(fields []configField) {
+	byKey := make(map[string]configField, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+	for _, f := range fields {
+		for _, prefix := range groupPrefixes {
+			base, ok := strings.CutPrefix(f.key, prefix)
+			if !ok || c.sources[f.key] != "default" {
+				continue
+			}
+			f.value.Set(byKey[base].value)
+			c.sources[f.key] = "inherited from " + base
+		}
+	}
+}
+
+// validate checks values that parse fine but make no sense, naming the key and its source.
+func (c *loadedConfig) validate() []error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, &configError{Key: key, Source: c.sources[key], Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		fail("port", "must be between 1 and 65535, got %d", c.Port)
+	}
+	if !slices.Contains([]string{"text", "json"}, strings.ToLower(c.LogFormat)) {
+		fail("log_format", "must be text or json, got %q", c.LogFormat)
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "warning", "error"}, strings.ToLower(c.LogLevel)) {
+		fail("log_level", "must be one of debug, info, warn, error, got %q", c.LogLevel)
+	}
+	if c.ShutdownTimeout <= 0 {
+		fail("shutdown_timeout", "must be a positive duration, got %v", c.ShutdownTimeout)
+	}
+	for _, t := range c.Tenant.Allowed {
+		if !tenantIDPattern.MatchString(t) {
+			fail("tenant.allowed", "invalid tenant ID %q", t)
+		}
+	}
+	if c.RateLimit.RequestsPerMinute < 0 {
+		fail("rate_limit.requests_per_minute", "must not be negative, got %d", c.RateLimit.RequestsPerMinute)
+	}
+	for _, cidr := range c.RateLimit.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			fail("rate_limit.trusted_proxies", "invalid CIDR %q", cidr)
+		}
+	}
+	if c.RequestID.Enabled && c.RequestID.Header == "" {
+		fail("request_id.header", "must not be empty while request_id.enabled is true")
+	}
+	if c.TrailingSlash.RedirectCode < 300 || c.TrailingSlash.RedirectCode > 399 {
+		fail("trailing_slash.redirect_code", "must be a 3xx status code, got %d", c.TrailingSlash.RedirectCode)
+	}
+	validateTheme(c.Theme, fail)
+	if uri := c.CSP.ReportURI; uri != "" && !strings.HasPrefix(uri, "/") && !strings.HasPrefix(uri, "https://") {
+		fail("csp.report_uri", "must be a path or an https:// URL, got %q", uri)
+	}
+	if strings.ContainsAny(c.CSP.ReportURI, " ;,") {
+		fail("csp.report_uri", "must not contain spaces, semicolons or commas")
+	}
+	if _, ok := appLocales[c.Locale.Default]; !ok {
+		fail("locale.default", "must be one of %s, got %q", strings.Join(localeTags, ", "), c.Locale.Default)
+	}
+	if c.Compression.MinSize < 0 {
+		fail("compression.min_size", "must not be negative, got %d", c.Compression.MinSize)
+	}
+	for _, enc := range c.Compression.Encodings {
+		if _, ok := compressionEncoders[enc]; !ok {
+			fail("compression.encodings", "unknown encoding %q, expected br, gzip or deflate", enc)
+		}
+	}
+	for _, ct := range c.Compression.ContentTypes {
+		if _, _, err := mime.ParseMediaType(ct); err != nil || !strings.Contains(ct, "/") {
+			fail("compression.content_types", "invalid media type %q", ct)
+		}
+	}
+	if c.Cache.MaxEntries < 0 {
+		fail("cache.max_entries", "must not be negative, got %d", c.Cache.MaxEntries)
+	}
+	for _, route := range c.Cache.Routes {
+		if _, _, err := parseCacheRoute(route); err != nil {
+			fail("cache.routes", "%v", err)
+		}
+	}
+
+	policies := []struct {
+		prefix   string
+		cors     corsSettings
+		security securitySettings
+	}{
+		{"", c.CORS, c.Security},
+		{"api.", c.API.CORS, c.API.Security},
+		{"pages.", c.Pages.CORS, c.Pages.Security},
+	}
+	for _, p := range policies {
+		// Values inherited from the top level were already checked there.
+		inherited := func(key string) bool {
+			return strings.HasPrefix(c.sources[p.prefix+key], "inherited")
+		}
+		check := func(key, format string, args ...any) {
+			if !inherited(key) {
+				fail(p.prefix+key, format, args...)
+			}
+		}
+		if p.cors.AllowCredentials && slices.Contains(p.cors.AllowedOrigins, "*") &&
+			!(inherited("cors.allow_credentials") && inherited("cors.allowed_origins")) {
+			fail(p.prefix+"cors.allow_credentials", "cannot be true while %scors.allowed_origins contains \"*\"", p.prefix)
+		}
+		if p.cors.MaxAgeSeconds < -1 {
+			check("cors.max_age_seconds", "must be -1 (disabled) or greater, got %d", p.cors.MaxAgeSeconds)
+		}
+		if p.security.FrameOptions != "" && !slices.Contains([]string{"DENY", "SAMEORIGIN"}, strings.ToUpper(p.security.FrameOptions)) {
+			check("security.frame_options", "must be DENY or SAMEORIGIN, got %q", p.security.FrameOptions)
+		}
+		if p.security.HSTSMaxAgeSeconds < 0 {
+			check("security.hsts_max_age_seconds", "must not be negative, got %d", p.security.HSTSMaxAgeSeconds)
+		}
+	}
+	return errs
+}
+
+// readConfigFile parses a JSON or TOML file into flat dotted keys, chosen by file extension.
+func readConfigFile(path string) (map[string]tomlValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		values, err := parseTOML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %w", filepath.Base(path), err)
+		}
+		return values, nil
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+				return nil, fmt.Errorf("config: %s:%d: %w", filepath.Base(path), line, err)
+			}
+			return nil, fmt.Errorf("config: %s: %w", filepath.Base(path), err)
+		}
+		values := make(map[string]tomlValue)
+		flattenJSON(doc, "", values)
+		return values, nil
+	default:
+		return nil, fmt.Errorf("config: %s: unsupported format, use .json or .toml", filepath.Base(path))
+	}
+}
+
+// flattenJSON converts nested JSON objects into dotted keys. JSON values carry no line numbers.
+func flattenJSON(doc map[string]any, prefix string, out map[string]tomlValue) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flattenJSON(nested, key, out)
+			continue
+		}
+		out[key] = tomlValue{value: v}
+	}
+}
+
+// setConfigValue assigns raw to the field, converting it to the field's type.
+// Strings, as found in environment variables and flags, are parsed; lists are comma-separated.
+func setConfigValue(field reflect.Value, raw any) error {
+	if n, ok := raw.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			raw = i
+		} else if f, err := n.Float64(); err == nil {
+			raw = f
+		}
+	}
+
+	switch field.Interface().(type) {
+	case time.Duration:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected a duration string such as \"30s\", got %s", describeValue(raw))
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		field.SetInt(int64(d))
+	case string:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %s", describeValue(raw))
+		}
+		field.SetString(s)
+	case int:
+		switch v := raw.(type) {
+		case int64:
+			field.SetInt(v)
+		case string:
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("expected an integer, got %q", v)
+			}
+			field.SetInt(int64(i))
+		default:
+			return fmt.Errorf("expected an integer, got %s", describeValue(raw))
+		}
+	case bool:
+		switch v := raw.(type) {
+		case bool:
+			field.SetBool(v)
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("expected true or false, got %s", describeValue(raw))
+		}
+	case []string:
+		var list []string
+		switch v := raw.(type) {
+		case string:
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		case []any:
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("element %d: expected a string, got %s", i, describeValue(item))
+				}
+				list = append(list, s)
+			}
+		default:
+			return fmt.Errorf("expected a list of strings, got %s", describeValue(raw))
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// describeValue names the type of a raw value for error messages.
+func describeValue(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int64, float64:
+		return "a number"
+	case []any:
+		return "a list"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// flagValue returns the current value of a flag-backed setting from the CLI context.
+func flagValue(ctx *nova.Context, f configField) any {
+	switch f.value.Interface().(type) {
+	case int:
+		return int64(ctx.Int(f.flag))
+	case bool:
+		return ctx.Bool(f.flag)
+	default:
+		return ctx.String(f.flag)
+	}
+}
+
+// explicitFlags returns the global flags present on the command line, by primary name.
+// Nova only reports flag values, not whether a flag was given, which is needed so
+// that an unset flag's default does not override the environment or the file.
+func explicitFlags(cli *nova.CLI, args []string) map[string]bool {
+	names := make(map[string]string)
+	for _, f := range cli.GlobalFlags {
+		names[f.GetName()] = f.GetName()
+		for _, alias := range f.GetAliases() {
+			names[alias] = f.GetName()
+		}
+	}
+
+	set := make(map[string]bool)
+	// Global flags end at the first non-flag argument, as in nova's own parsing.
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		name, _, _ = strings.Cut(name, "=")
+		if primary, ok := names[name]; ok {
+			set[primary] = true
+		}
+	}
+	return set
+}
+
+// envName returns the environment variable that overrides key.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// suggestKey proposes a known key that differs only slightly from an unknown one.
+func suggestKey(key string, known map[string]configField) string {
+	best, bestDist := "", 3
+	for k := range known {
+		if d := editDistance(key, k); d < bestDist || d == bestDist && best != "" && k < best {
+			best, bestDist = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
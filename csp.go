@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// cspNonceKey stores the CSP nonce of the request in the request context.
+const cspNonceKey contextKey = "csp_nonce"
+
+// cspReportPath receives the violation reports of browsers.
+const cspReportPath = "/csp-report"
+
+// maxCSPReportSize bounds the body of a violation report; real reports are a few hundred bytes.
+const maxCSPReportSize = 64 << 10
+
+// cspPolicy builds a Content-Security-Policy header value, keeping directives in the
+// order they were added.
+type cspPolicy struct {
+	names   []string
+	sources map[string][]string
+}
+
+// newCSPPolicy returns an empty policy.
+func newCSPPolicy() *cspPolicy {
+	return &cspPolicy{sources: make(map[string][]string)}
+}
+
+// add appends sources to directive, creating the directive if needed.
+func (p *cspPolicy) add(directive string, sources ...string) *cspPolicy {
+	if _, ok := p.sources[directive]; !ok {
+		p.names = append(p.names, directive)
+	}
+	p.sources[directive] = append(p.sources[directive], sources...)
+	return p
+}
+
+// String renders the policy as a header value.
+func (p *cspPolicy) String() string {
+	parts := make([]string, 0, len(p.names))
+	for _, name := range p.names {
+		parts = append(parts, strings.TrimSpace(name+" "+strings.Join(p.sources[name], " ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// pagePolicy is the policy of the HTML pages. Scripts and styles must come from this
+// origin or carry the request's nonce, so inline code injected into a page does not run.
+func pagePolicy(nonce, reportURI string) *cspPolicy {
+	p := newCSPPolicy().
+		add("default-src", "'self'").
+		add("script-src", "'self'", "'nonce-"+nonce+"'").
+		add("style-src", "'self'", "'nonce-"+nonce+"'").
+		add("img-src", "'self'", "data:").
+		add("connect-src", "'self'").
+		add("object-src", "'none'").
+		add("base-uri", "'self'").
+		add("form-action", "'self'").
+		add("frame-ancestors", "'none'")
+	if reportURI != "" {
+		p.add("report-uri", reportURI)
+	}
+	return p
+}
+
+// cspMiddleware sets the Content-Security-Policy of the HTML pages with a fresh nonce
+// per request. Other routes, such as the Swagger UI which loads its scripts from a CDN,
+// get no policy. In report-only mode violations are reported but nothing is blocked.
+func cspMiddleware(settings cspSettings) nova.Middleware {
+	header := "Content-Security-Policy"
+	if settings.ReportOnly {
+		header = "Content-Security-Policy-Report-Only"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isPageRoute(knownRoutes.lookup(r.URL.Path)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			nonce := newCSPNonce()
+			w.Header().Set(header, pagePolicy(nonce, settings.ReportURI).String())
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce)))
+		})
+	}
+}
+
+// newCSPNonce returns 128 random bits, base64-encoded.
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// cspNonce returns the nonce of the request, or "" when no policy applies to it.
+// Handlers that render their own <script> or <style> elements set it as the nonce attribute.
+func cspNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey).(string)
+	return nonce
+}
+
+// withNonce sets the request's nonce on every element, so inline scripts and styles are
+// allowed by the policy. Elements that are not *nova.Element are left unchanged.
+func withNonce(ctx context.Context, elements []nova.HTMLElement) []nova.HTMLElement {
+	nonce := cspNonce(ctx)
+	if nonce == "" {
+		return elements
+	}
+	for _, el := range elements {
+		if e, ok := el.(*nova.Element); ok {
+			e.Attr("nonce", nonce)
+		}
+	}
+	return elements
+}
+
+// cspViolation holds the fields of a violation report that are worth logging.
+// Browsers send them in the legacy report-uri format with kebab-case names and in
+// the Reporting API format with camelCase names.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+
+	DocumentURL                 string `json:"documentURL"`
+	ReportingEffectiveDirective string `json:"effectiveDirective"`
+	BlockedURL                  string `json:"blockedURL"`
+	ReportingSourceFile         string `json:"sourceFile"`
+	ReportingLineNumber         int    `json:"lineNumber"`
+}
+
+// handleCSPReport logs the violations reported by browsers. Reports never get an
+// error response, since browsers ignore it anyway.
+func handleCSPReport(rc *nova.ResponseContext) error {
+	body, err := io.ReadAll(io.LimitReader(rc.Request().Body, maxCSPReportSize))
+	if err != nil {
+		rc.Writer().WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	var violations []cspViolation
+	var legacy struct {
+		Report *cspViolation `json:"csp-report"`
+	}
+	var reports []struct {
+		Type string       `json:"type"`
+		Body cspViolation `json:"body"`
+	}
+	switch {
+	case json.Unmarshal(body, &legacy) == nil && legacy.Report != nil:
+		violations = append(violations, *legacy.Report)
+	case json.Unmarshal(body, &reports) == nil:
+		for _, r := range reports {
+			if r.Type == "csp-violation" {
+				violations = append(violations, r.Body)
+			}
+		}
+	default:
+		loggerFrom(rc.Request().Context()).Debug("Malformed CSP report", "size", len(body))
+	}
+
+	logger := loggerFrom(rc.Request().Context())
+	for _, v := range violations {
+		logger.Warn("CSP violation",
+			"document", firstNonEmpty(v.DocumentURI, v.DocumentURL),
+			"directive", firstNonEmpty(v.EffectiveDirective, v.ReportingEffectiveDirective, v.ViolatedDirective),
+			"blocked", firstNonEmpty(v.BlockedURI, v.BlockedURL),
+			"source", firstNonEmpty(v.SourceFile, v.ReportingSourceFile),
+			"line", max(v.LineNumber, v.ReportingLineNumber),
+			"disposition", v.Disposition,
+		)
+	}
+	rc.Writer().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
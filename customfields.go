@@ -0,0 +1,477 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// adminHeader carries the admin secret on requests that manage custom fields.
+const adminHeader = "X-Admin-Key"
+
+// adminSecretKey stores the configured admin secret in the request context.
+const adminSecretKey contextKey = "admin_secret"
+
+// CustomField defines an additional field that the items of a tenant carry in their
+// customFields object. The constraints apply to the type they are named after.
+type CustomField struct {
+	Name      string   `json:"name" minlength:"1" maxlength:"32" pattern:"^[a-z][a-z0-9_]*$" description:"Key of the field in the customFields object of items"`
+	Label     string   `json:"label,omitempty" maxlength:"50" description:"Label shown in the HTML pages; the name if empty"`
+	Type      string   `json:"type" enum:"string|number|bool|date|enum" description:"Type of the values"`
+	Required  bool     `json:"required,omitempty" description:"Whether items must have a value"`
+	MinLength int      `json:"minLength,omitempty" min:"0" description:"string: minimum number of characters"`
+	MaxLength int      `json:"maxLength,omitempty" min:"0" description:"string: maximum number of characters"`
+	Pattern   string   `json:"pattern,omitempty" maxlength:"200" description:"string: regular expression values must match"`
+	Min       *float64 `json:"min,omitempty" description:"number: smallest allowed value"`
+	Max       *float64 `json:"max,omitempty" description:"number: largest allowed value"`
+	Options   []string `json:"options,omitempty" maxItems:"50" uniqueItems:"true" maxlength:"50" description:"enum: allowed values"`
+
+	patternRegexp *regexp.Regexp // Pattern compiled by check
+}
+
+// label returns the label of the field in the HTML pages.
+func (f CustomField) label() string {
+	return cmp.Or(f.Label, f.Name)
+}
+
+// check reports the problems of a definition that its struct tags cannot express, and
+// compiles its pattern once for the values checked by parse.
+func (f *CustomField) check() []violation {
+	var violations []violation
+	if f.Pattern != "" {
+		re, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			violations = append(violations, violation{Field: "pattern", Rule: "regexp"})
+		}
+		f.patternRegexp = re
+	}
+	if f.MaxLength > 0 && f.MaxLength < f.MinLength {
+		violations = append(violations, violation{Field: "maxLength", Rule: "min", Args: []any{f.MinLength}})
+	}
+	if f.Min != nil && f.Max != nil && *f.Max < *f.Min {
+		violations = append(violations, violation{Field: "max", Rule: "min", Args: []any{*f.Min}})
+	}
+	if f.Type == "enum" && len(f.Options) == 0 {
+		violations = append(violations, violation{Field: "options", Rule: "minItems", Args: []any{1}})
+	}
+	return violations
+}
+
+// parse converts a value of the field, as decoded from JSON or sent by a form, to the
+// value stored with the item. Empty form values count as missing. The violations use
+// the name of the field in the input, customFields.<name>.
+func (f CustomField) parse(value any) (any, []violation) {
+	field := "customFields." + f.Name
+	if s, ok := value.(string); ok && f.Type != "string" && s == "" {
+		value = nil
+	}
+	if value == nil {
+		if f.Required {
+			return nil, []violation{{Field: field, Rule: "required"}}
+		}
+		return nil, nil
+	}
+
+	mismatch := []violation{{Field: field, Rule: "type", Args: []any{f.Type}}}
+	switch f.Type {
+	case "number":
+		n, ok := value.(float64)
+		if s, isString := value.(string); isString {
+			var err error
+			n, err = strconv.ParseFloat(s, 64)
+			ok = err == nil
+		}
+		if !ok {
+			return nil, mismatch
+		}
+		var violations []violation
+		if f.Min != nil && n < *f.Min {
+			violations = append(violations, violation{Field: field, Rule: "min", Args: []any{*f.Min}})
+		}
+		if f.Max != nil && n > *f.Max {
+			violations = append(violations, violation{Field: field, Rule: "max", Args: []any{*f.Max}})
+		}
+		return n, violations
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch v {
+			case "on", "true", "1":
+				return true, nil
+			case "off", "false", "0":
+				return false, nil
+			}
+		}
+		return nil, mismatch
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil, mismatch
+	}
+	switch f.Type {
+	case "date":
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, []violation{{Field: field, Rule: "date"}}
+		}
+	case "enum":
+		if !slices.Contains(f.Options, s) {
+			return nil, []violation{{Field: field, Rule: "enum", Args: []any{strings.Join(f.Options, ", ")}}}
+		}
+	case "string":
+		if s == "" {
+			if f.Required {
+				return nil, []violation{{Field: field, Rule: "required"}}
+			}
+			return nil, nil
+		}
+		var violations []violation
+		if f.MinLength > 0 && len([]rune(s)) < f.MinLength {
+			violations = append(violations, violation{Field: field, Rule: "minlength", Args: []any{f.MinLength}})
+		}
+		if f.MaxLength > 0 && len([]rune(s)) > f.MaxLength {
+			violations = append(violations, violation{Field: field, Rule: "maxlength", Args: []any{f.MaxLength}})
+		}
+		if f.patternRegexp != nil && !f.patternRegexp.MatchString(s) {
+			violations = append(violations, violation{Field: field, Rule: "pattern"})
+		}
+		return s, violations
+	}
+	return s, nil
+}
+
+// customFieldsOf returns the field definitions of tenant in the order they were added.
+func customFieldsOf(tenant string) []CustomField {
+	mu.Lock()
+	defer mu.Unlock()
+	return slices.Clone(readStore(tenant).fields)
+}
+
+// parseCustomFields checks the custom field values of an input against the definitions
+// and returns the values to store. Values of undefined fields are rejected.
+func parseCustomFields(fields []CustomField, values map[string]any) (map[string]any, []violation) {
+	var violations []violation
+	for name := range values {
+		if !slices.ContainsFunc(fields, func(f CustomField) bool { return f.Name == name }) {
+			violations = append(violations, violation{Field: "customFields." + name, Rule: "unknown"})
+		}
+	}
+	slices.SortFunc(violations, func(a, b violation) int { return cmp.Compare(a.Field, b.Field) })
+
+	parsed := make(map[string]any, len(fields))
+	for _, f := range fields {
+		value, vs := f.parse(values[f.Name])
+		violations = append(violations, vs...)
+		if value != nil {
+			parsed[f.Name] = value
+		}
+	}
+	if len(parsed) == 0 {
+		parsed = nil
+	}
+	return parsed, violations
+}
+
+// bindItemInput binds and validates an item input like bindValidated, including its
+// custom fields, which forms send as customFields.<name>.
+func bindItemInput(rc *nova.ResponseContext, input *NewItemInput) *inputError {
+	err := bindValidated(rc, input)
+	if err != nil && err.Code != codeInvalidInput {
+		return err
+	}
+
+	fields := customFieldsOf(tenantFrom(rc.Request().Context()))
+	if !strings.Contains(rc.Request().Header.Get("Content-Type"), "application/json") {
+		input.CustomFields = make(map[string]any)
+		for _, f := range fields {
+			if values, ok := rc.Request().Form["customFields."+f.Name]; ok {
+				input.CustomFields[f.Name] = values[0]
+			} else if f.Type == "bool" {
+				// Unchecked checkboxes send nothing
+				input.CustomFields[f.Name] = false
+			}
+		}
+	}
+	values, violations := parseCustomFields(fields, input.CustomFields)
+	if len(violations) == 0 {
+		input.CustomFields = values
+		return err
+	}
+	if err == nil {
+		err = &inputError{Code: codeInvalidInput}
+	}
+	err.Violations = append(err.Violations, violations...)
+	return err
+}
+
+// adminMiddleware makes the admin secret available to the handlers managing custom fields.
+func adminMiddleware(settings adminSettings) nova.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminSecretKey, settings.Secret)))
+		})
+	}
+}
+
+// isAdmin reports whether r carries the admin secret. Without a configured secret
+// nobody is an admin.
+func isAdmin(r *http.Request) bool {
+	secret, _ := r.Context().Value(adminSecretKey).(string)
+	key := r.Header.Get(adminHeader)
+	return secret != "" && subtle.ConstantTimeCompare([]byte(key), []byte(secret)) == 1
+}
+
+// setupCustomFieldRoutes configures the API listing and managing the custom fields of a tenant.
+func setupCustomFieldRoutes(api *nova.Group) {
+	forbidden := nova.ResponseOption{Description: "Missing or wrong " + adminHeader + " header", Body: &ErrorResponse{}}
+
+	api.GetFunc("/fields", handleGetCustomFields, &nova.RouteOptions{
+		Tags:        []string{"Custom fields"},
+		Summary:     "List custom fields",
+		Description: "Lists the custom fields the items of the tenant carry in their customFields object.",
+		Responses: map[int]nova.ResponseOption{
+			http.StatusOK: {Description: "Custom field definitions", Body: []CustomField{}},
+		},
+	})
+
+	api.PutFunc("/fields/{fieldName}", handlePutCustomField, &nova.RouteOptions{
+		Tags:        []string{"Custom fields"},
+		Summary:     "Define a custom field",
+		Description: "Adds a custom field or replaces its definition. Stored values that do not fit a new definition are removed. Needs the " + adminHeader + " header.",
+		OperationID: "putCustomField",
+		Parameters:  customFieldNameParameter("The name of the field to define"),
+		RequestBody: &CustomField{},
+		Responses: map[int]nova.ResponseOption{
+			http.StatusOK:                    {Description: "Definition replaced", Body: &CustomField{}},
+			http.StatusCreated:               {Description: "Field added", Body: &CustomField{}},
+			http.StatusBadRequest:            {Description: "Invalid definition", Body: &ErrorResponse{}},
+			http.StatusRequestEntityTooLarge: {Description: "Request body over 1 MiB", Body: &ErrorResponse{}},
+			http.StatusForbidden:             forbidden,
+		},
+	})
+
+	api.DeleteFunc("/fields/{fieldName}", handleDeleteCustomField, &nova.RouteOptions{
+		Tags:        []string{"Custom fields"},
+		Summary:     "Remove a custom field",
+		Description: "Removes a custom field and its values from all items. Needs the " + adminHeader + " header.",
+		OperationID: "deleteCustomField",
+		Parameters:  customFieldNameParameter("The name of the field to remove"),
+		Responses: map[int]nova.ResponseOption{
+			http.StatusNoContent: {Description: "Field removed"},
+			http.StatusForbidden: forbidden,
+			http.StatusNotFound:  {Description: "Field not found", Body: &ErrorResponse{}},
+		},
+	})
+}
+
+// customFieldNameParameter documents the {fieldName} path parameter.
+func customFieldNameParameter(description string) []nova.ParameterOption {
+	return []nova.ParameterOption{{
+		Name:        "fieldName",
+		In:          "path",
+		Description: description,
+		Schema:      "",
+	}}
+}
+
+// handleGetCustomFields lists the custom fields of the tenant.
+func handleGetCustomFields(rc *nova.ResponseContext) error {
+	fields := customFieldsOf(tenantFrom(rc.Request().Context()))
+	if fields == nil {
+		fields = []CustomField{}
+	}
+	return rc.JSON(http.StatusOK, fields)
+}
+
+// handlePutCustomField adds or replaces the custom field named in the path.
+func handlePutCustomField(rc *nova.ResponseContext) error {
+	if !isAdmin(rc.Request()) {
+		return jsonError(rc, http.StatusForbidden, codeAdminRequired, adminHeader)
+	}
+
+	name := rc.URLParam("fieldName")
+	def := CustomField{Name: name}
+	err := bindValidated(rc, &def)
+	if err != nil && err.Code != codeInvalidInput {
+		return rc.JSON(err.Status(), err.Response(rc.Request().Context()))
+	}
+	violations := def.check()
+	if def.Name != name {
+		// The name in the body, if any, must be the one in the path
+		violations = append(violations, violation{Field: "name", Rule: "mismatch", Args: []any{name}})
+	}
+	if len(violations) > 0 {
+		if err == nil {
+			err = &inputError{Code: codeInvalidInput}
+		}
+		err.Violations = append(err.Violations, violations...)
+	}
+	if err != nil {
+		loggerFrom(rc.Request().Context()).Info("Custom field rejected", "field", name, "error", err)
+		return rc.JSON(err.Status(), err.Response(rc.Request().Context()))
+	}
+
+	tenant := tenantFrom(rc.Request().Context())
+	mu.Lock()
+	store := storeFor(tenant)
+	i := slices.IndexFunc(store.fields, func(f CustomField) bool { return f.Name == name })
+	if i >= 0 {
+		store.fields[i] = def
+	} else {
+		store.fields = append(store.fields, def)
+	}
+	removed := store.pruneCustomField(def)
+	mu.Unlock()
+	// The pages and API responses show the custom fields of the items
+	notifyItemsChanged(tenant)
+
+	loggerFrom(rc.Request().Context()).Info("Custom field defined", "field", name, "type", def.Type, "removed_values", removed)
+	if i >= 0 {
+		return rc.JSON(http.StatusOK, def)
+	}
+	return rc.JSON(http.StatusCreated, def)
+}
+
+// handleDeleteCustomField removes the custom field named in the path and its values.
+func handleDeleteCustomField(rc *nova.ResponseContext) error {
+	if !isAdmin(rc.Request()) {
+		return jsonError(rc, http.StatusForbidden, codeAdminRequired, adminHeader)
+	}
+
+	name := rc.URLParam("fieldName")
+	tenant := tenantFrom(rc.Request().Context())
+	mu.Lock()
+	store := readStore(tenant)
+	i := slices.IndexFunc(store.fields, func(f CustomField) bool { return f.Name == name })
+	if i >= 0 {
+		store.fields = slices.Delete(store.fields, i, i+1)
+		store.pruneCustomField(CustomField{Name: name})
+	}
+	mu.Unlock()
+
+	if i < 0 {
+		return jsonError(rc, http.StatusNotFound, codeFieldNotFound, name)
+	}
+	notifyItemsChanged(tenant)
+	loggerFrom(rc.Request().Context()).Info("Custom field removed", "field", name)
+	rc.Writer().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// pruneCustomField converts the stored values of the field def.Name to def's type and
+// removes those that do not fit def, or all of them when def has no type because the
+// field was removed. It returns the number of removed values. The caller must hold mu.
+func (s *itemStore) pruneCustomField(def CustomField) int {
+	// Required only matters when items are saved; existing items may lack the value
+	def.Required = false
+	removed := 0
+	for id, item := range s.items {
+		value, ok := item.CustomFields[def.Name]
+		if !ok {
+			continue
+		}
+		item.CustomFields = maps.Clone(item.CustomFields)
+		parsed, violations := def.parse(value)
+		if def.Type != "" && len(violations) == 0 && parsed != nil {
+			// A field retyped from string to number turns "12" into 12
+			item.CustomFields[def.Name] = parsed
+		} else {
+			delete(item.CustomFields, def.Name)
+			removed++
+		}
+		if len(item.CustomFields) == 0 {
+			item.CustomFields = nil
+		}
+		s.items[id] = item
+	}
+	return removed
+}
+
+// customFieldValue renders the value of a custom field in the HTML pages.
+func customFieldValue(l *locale, f CustomField, value any) nova.HTMLElement {
+	switch v := value.(type) {
+	case nil:
+		return nova.Text("")
+	case bool:
+		if v {
+			return nova.Text(l.T("custom.yes"))
+		}
+		return nova.Text(l.T("custom.no"))
+	case float64:
+		return nova.Text(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		if f.Type == "date" {
+			if t, err := time.Parse(time.DateOnly, v); err == nil {
+				return nova.TimeEl(nova.Text(l.Date(t))).Attr("datetime", v)
+			}
+		}
+		return nova.Text(v)
+	}
+	return nova.Text(fmt.Sprint(value))
+}
+
+// customFieldInput renders the form control of a custom field holding value.
+func customFieldInput(l *locale, f CustomField, value any) nova.HTMLElement {
+	name := "customFields." + f.Name
+	id := "custom-" + f.Name
+	label := nova.Label().Text(f.label()+":").Attr("for", id)
+
+	var input *nova.Element
+	switch f.Type {
+	case "bool":
+		checkbox := nova.CheckboxInput(name).ID(id)
+		if v, _ := value.(bool); v {
+			checkbox.Attr("checked", "true")
+		}
+		return nova.Div(nova.Label(checkbox, nova.Text(" "+f.label()))).Class("form-group")
+	case "enum":
+		input = nova.Select().Attr("name", name)
+		if !f.Required {
+			input.Add(selectOption("", l.T("custom.none"), value == nil))
+		}
+		for _, option := range f.Options {
+			input.Add(selectOption(option, option, value == option))
+		}
+	case "number":
+		input = nova.NumberInput(name).Attr("step", "any")
+		if f.Min != nil {
+			input.Attr("min", strconv.FormatFloat(*f.Min, 'f', -1, 64))
+		}
+		if f.Max != nil {
+			input.Attr("max", strconv.FormatFloat(*f.Max, 'f', -1, 64))
+		}
+		if v, ok := value.(float64); ok {
+			input.Attr("value", strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	case "date":
+		input = nova.DateInput(name)
+	default:
+		input = nova.TextInput(name)
+		if f.MinLength > 0 {
+			input.Attr("minlength", strconv.Itoa(f.MinLength))
+		}
+		if f.MaxLength > 0 {
+			input.Attr("maxlength", strconv.Itoa(f.MaxLength))
+		}
+	}
+	if s, ok := value.(string); ok && f.Type != "enum" {
+		input.Attr("value", s)
+	}
+	input.ID(id)
+	if f.Required {
+		input.Attr("required", "true")
+	}
+	return nova.Div(label, input).Class("form-group")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+func TestCustomFieldParse(t *testing.T) {
+	one, ten := 1.0, 10.0
+	code := CustomField{Name: "code", Type: "string", MinLength: 2, MaxLength: 4, Pattern: "^[A-Z]+$"}
+	if violations := code.check(); len(violations) > 0 {
+		t.Fatalf("check() = %+v", violations)
+	}
+
+	tests := []struct {
+		name   string
+		field  CustomField
+		value  any
+		want   any
+		wantVs []violation
+	}{
+		{name: "string", field: code, value: "AB", want: "AB"},
+		{name: "string too short", field: code, value: "A", wantVs: []violation{{Field: "customFields.code", Rule: "minlength", Args: []any{2}}}},
+		{name: "string too long", field: code, value: "ABCDE", wantVs: []violation{{Field: "customFields.code", Rule: "maxlength", Args: []any{4}}}},
+		{name: "string pattern", field: code, value: "ab", wantVs: []violation{{Field: "customFields.code", Rule: "pattern"}}},
+		{name: "empty string", field: code, value: ""},
+		{name: "string type", field: code, value: 12.0, wantVs: []violation{{Field: "customFields.code", Rule: "type", Args: []any{"string"}}}},
+		{name: "required missing", field: CustomField{Name: "code", Type: "string", Required: true}, wantVs: []violation{{Field: "customFields.code", Rule: "required"}}},
+		{name: "number", field: CustomField{Name: "n", Type: "number", Min: &one, Max: &ten}, value: 5.0, want: 5.0},
+		{name: "number from form", field: CustomField{Name: "n", Type: "number"}, value: "2.5", want: 2.5},
+		{name: "number below min", field: CustomField{Name: "n", Type: "number", Min: &one}, value: 0.5, want: 0.5, wantVs: []violation{{Field: "customFields.n", Rule: "min", Args: []any{1.0}}}},
+		{name: "number above max", field: CustomField{Name: "n", Type: "number", Max: &ten}, value: 11.0, want: 11.0, wantVs: []violation{{Field: "customFields.n", Rule: "max", Args: []any{10.0}}}},
+		{name: "not a number", field: CustomField{Name: "n", Type: "number"}, value: "abc", wantVs: []violation{{Field: "customFields.n", Rule: "type", Args: []any{"number"}}}},
+		{name: "empty form number", field: CustomField{Name: "n", Type: "number"}, value: ""},
+		{name: "bool", field: CustomField{Name: "b", Type: "bool"}, value: true, want: true},
+		{name: "bool from checkbox", field: CustomField{Name: "b", Type: "bool"}, value: "on", want: true},
+		{name: "bool type", field: CustomField{Name: "b", Type: "bool"}, value: "yes", wantVs: []violation{{Field: "customFields.b", Rule: "type", Args: []any{"bool"}}}},
+		{name: "date", field: CustomField{Name: "d", Type: "date"}, value: "2026-02-28", want: "2026-02-28"},
+		{name: "invalid date", field: CustomField{Name: "d", Type: "date"}, value: "2026-02-30", wantVs: []violation{{Field: "customFields.d", Rule: "date"}}},
+		{name: "enum", field: CustomField{Name: "e", Type: "enum", Options: []string{"a", "b"}}, value: "b", want: "b"},
+		{name: "enum option", field: CustomField{Name: "e", Type: "enum", Options: []string{"a", "b"}}, value: "c", wantVs: []violation{{Field: "customFields.e", Rule: "enum", Args: []any{"a, b"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, violations := tt.field.parse(tt.value)
+			if !reflect.DeepEqual(violations, tt.wantVs) {
+				t.Errorf("parse() violations = %+v, want %+v", violations, tt.wantVs)
+			}
+			if tt.wantVs == nil && got != tt.want {
+				t.Errorf("parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomFieldCheck(t *testing.T) {
+	one, ten := 1.0, 10.0
+	tests := []struct {
+		name  string
+		field CustomField
+		want  []violation
+	}{
+		{name: "valid", field: CustomField{Name: "n", Type: "number", Min: &one, Max: &ten}},
+		{name: "invalid pattern", field: CustomField{Name: "s", Type: "string", Pattern: "["}, want: []violation{{Field: "pattern", Rule: "regexp"}}},
+		{name: "max length below min length", field: CustomField{Name: "s", Type: "string", MinLength: 5, MaxLength: 2}, want: []violation{{Field: "maxLength", Rule: "min", Args: []any{5}}}},
+		{name: "max below min", field: CustomField{Name: "n", Type: "number", Min: &ten, Max: &one}, want: []violation{{Field: "max", Rule: "min", Args: []any{10.0}}}},
+		{name: "enum without options", field: CustomField{Name: "e", Type: "enum"}, want: []violation{{Field: "options", Rule: "minItems", Args: []any{1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.field.check(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCustomFields(t *testing.T) {
+	fields := []CustomField{{Name: "n", Type: "number", Required: true}, {Name: "s", Type: "string"}}
+	parsed, violations := parseCustomFields(fields, map[string]any{"n": 1.0, "zeta": 1, "alpha": "x"})
+	want := []violation{
+		{Field: "customFields.alpha", Rule: "unknown"},
+		{Field: "customFields.zeta", Rule: "unknown"},
+	}
+	if !reflect.DeepEqual(violations, want) {
+		t.Errorf("violations = %+v, want %+v", violations, want)
+	}
+	if !reflect.DeepEqual(parsed, map[string]any{"n": 1.0}) {
+		t.Errorf("parsed = %+v, want only n", parsed)
+	}
+}
+
+func TestPruneCustomField(t *testing.T) {
+	store := &itemStore{items: map[int]Item{
+		1: {ID: 1, CustomFields: map[string]any{"size": "12", "code": "a"}},
+		2: {ID: 2, CustomFields: map[string]any{"size": "large"}},
+		3: {ID: 3, CustomFields: map[string]any{"size": ""}},
+		4: {ID: 4},
+	}}
+	if removed := store.pruneCustomField(CustomField{Name: "size", Type: "number"}); removed != 2 {
+		t.Errorf("removed = %d, want 2", removed)
+	}
+	want := map[int]map[string]any{
+		1: {"size": 12.0, "code": "a"},
+		2: nil,
+		3: nil,
+		4: nil,
+	}
+	for id, fields := range want {
+		if got := store.items[id].CustomFields; !reflect.DeepEqual(got, fields) {
+			t.Errorf("item %d custom fields = %#v, want %#v", id, got, fields)
+		}
+	}
+
+	if removed := store.pruneCustomField(CustomField{Name: "size"}); removed != 1 {
+		t.Errorf("removed = %d after deleting the field, want 1", removed)
+	}
+	if got := store.items[1].CustomFields; !reflect.DeepEqual(got, map[string]any{"code": "a"}) {
+		t.Errorf("item 1 custom fields = %#v after deleting the field, want only code", got)
+	}
+}
+
+func TestPutCustomField(t *testing.T) {
+	const tenant = "custom-fields-test"
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(stores, tenant)
+		mu.Unlock()
+	})
+
+	router := nova.NewRouter()
+	router.Use(adminMiddleware(adminSettings{Secret: "admin"}), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey, tenant)))
+		})
+	})
+	router.PutFunc("/fields/{fieldName}", handlePutCustomField)
+
+	tests := []struct {
+		name     string
+		path     string
+		adminKey string
+		body     string
+		wantCode int
+		wantRule string
+	}{
+		{name: "no admin key", path: "/fields/code", body: `{"type":"string"}`, wantCode: http.StatusForbidden},
+		{name: "name mismatch", path: "/fields/code", adminKey: "admin", body: `{"name":"other","type":"string"}`, wantCode: http.StatusBadRequest, wantRule: "mismatch"},
+		{name: "invalid pattern", path: "/fields/code", adminKey: "admin", body: `{"type":"string","pattern":"["}`, wantCode: http.StatusBadRequest, wantRule: "regexp"},
+		{name: "created", path: "/fields/code", adminKey: "admin", body: `{"type":"string","pattern":"^[A-Z]+$"}`, wantCode: http.StatusCreated},
+		{name: "replaced", path: "/fields/code", adminKey: "admin", body: `{"name":"code","type":"string","pattern":"^[a-z]+$"}`, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.adminKey != "" {
+				r.Header.Set(adminHeader, tt.adminKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantRule == "" {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Details) != 1 || resp.Details[0].Code != tt.wantRule {
+				t.Errorf("details = %+v, want one %q", resp.Details, tt.wantRule)
+			}
+		})
+	}
+
+	// The stored definition checks values with its compiled pattern
+	fields := customFieldsOf(tenant)
+	if len(fields) != 1 {
+		t.Fatalf("fields = %+v, want one", fields)
+	}
+	if _, violations := fields[0].parse("abc"); len(violations) > 0 {
+		t.Errorf("parse(%q) = %+v, want no violations", "abc", violations)
+	}
+	if _, violations := fields[0].parse("ABC"); len(violations) != 1 || violations[0].Rule != "pattern" {
+		t.Errorf("parse(%q) = %+v, want a pattern violation", "ABC", violations)
+	}
+}
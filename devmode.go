@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/xlc-dev/nova/nova"
+)
+
+// Environment variables through which the dev supervisor configures the server process it runs.
+const (
+	// listenFDEnv names the inherited file descriptor of the listening socket.
+	listenFDEnv = "NOVA_LISTEN_FD"
+	// readyFDEnv names the inherited pipe the server writes to once it accepts requests.
+	readyFDEnv = "NOVA_READY_FD"
+	// devBuildEnv identifies the build being run; pages only include the reload script when it is set.
+	devBuildEnv = "NOVA_DEV_BUILD"
+)
+
+// devReloadPath is the event stream the reload script listens to.
+const devReloadPath = "/__nova/reload"
+
+// devRebuildDelay lets a burst of file events, such as an editor saving several files, settle into one rebuild.
+const devRebuildDelay = 300 * time.Millisecond
+
+// devBuildID is the build this process was started as by the dev supervisor, or "" outside of dev mode.
+var devBuildID = os.Getenv(devBuildEnv)
+
+// devServer is the supervisor behind --watch. It owns the listening socket and hands it
+// to each build it runs, so a restart never refuses connections: the new build accepts
+// on the same socket before the previous one is asked to drain.
+type devServer struct {
+	cfg      *appConfig
+	dir      string
+	binDir   string
+	listener *os.File
+	// args are the command-line arguments for the server process, without --watch.
+	args  []string
+	build int
+	child *exec.Cmd
+	// exited receives every server process that exits, including replaced ones.
+	exited chan childExit
+}
+
+// childExit reports that a server process exited.
+type childExit struct {
+	cmd *exec.Cmd
+	err error
+}
+
+// runDevServer watches the source tree for files with the configured extensions, rebuilds
+// the binary on every change and replaces the running server with the new build. A failed
+// build keeps the previous one running.
+func runDevServer(cfg *appConfig) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("dev: %w", err)
+	}
+	binDir, err := os.MkdirTemp("", "nova-dev-")
+	if err != nil {
+		return fmt.Errorf("dev: %w", err)
+	}
+	defer os.RemoveAll(binDir)
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return &exitError{exitFailure, fmt.Errorf("failed to listen on %s: %w", addr, err)}
+	}
+	defer ln.Close()
+	lnFile, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		return fmt.Errorf("dev: %w", err)
+	}
+	defer lnFile.Close()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("dev: failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watchTree(watcher, dir); err != nil {
+		return fmt.Errorf("dev: %w", err)
+	}
+
+	d := &devServer{
+		cfg:      cfg,
+		dir:      dir,
+		binDir:   binDir,
+		listener: lnFile,
+		args:     withoutWatchFlag(os.Args[1:]),
+		exited:   make(chan childExit, 4),
+	}
+	slog.Info("Dev server watching for changes", "dir", dir, "extensions", cfg.Extensions, "host", cfg.Host, "port", cfg.Port)
+	d.rebuild()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case sig := <-sigCh:
+			slog.Info("Stopping dev server", "signal", sig)
+			return d.stop()
+		case exit := <-d.exited:
+			// Replaced builds are expected to exit; only the running one stopping is an error.
+			if exit.cmd != d.child {
+				continue
+			}
+			d.child = nil
+			slog.Error("Server process exited, waiting for changes", "error", exit.err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					_ = watchTree(watcher, ev.Name)
+					continue
+				}
+			}
+			if !hasWatchedExtension(ev.Name, cfg.Extensions) {
+				continue
+			}
+			if cfg.Verbose {
+				slog.Info("File changed", "file", ev.Name, "op", ev.Op.String())
+			}
+			debounce = time.After(devRebuildDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("Watcher error", "error", err)
+		case <-debounce:
+			debounce = nil
+			d.rebuild()
+		}
+	}
+}
+
+// rebuild compiles the source tree and, if that succeeds, replaces the running server with the new build.
+func (d *devServer) rebuild() {
+	d.build++
+	bin := filepath.Join(d.binDir, fmt.Sprintf("server-%d", d.build))
+
+	start := time.Now()
+	cmd := exec.Command("go", "build", "-o", bin, ".")
+	cmd.Dir = d.dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		slog.Error("Build failed, keeping the previous build running", "build", d.build, "error", err)
+		return
+	}
+	slog.Info("Build succeeded", "build", d.build, "duration", time.Since(start).Round(time.Millisecond).String())
+
+	next, err := d.start(bin)
+	if err != nil {
+		slog.Error("New build failed to start, keeping the previous build running", "build", d.build, "error", err)
+		return
+	}
+
+	prev := d.child
+	d.child = next
+	if prev != nil {
+		// The new build already accepts connections, so the previous one can drain at its own pace.
+		_ = prev.Process.Signal(syscall.SIGTERM)
+	}
+	os.Remove(bin)
+}
+
+// start runs bin on the shared listener and waits until it reports that it accepts requests.
+func (d *devServer) start(bin string) (*exec.Cmd, error) {
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+
+	buildID := fmt.Sprintf("%d-%d", d.build, time.Now().UnixNano())
+	cmd := exec.Command(bin, d.args...)
+	cmd.Dir = d.dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// ExtraFiles start at descriptor 3.
+	cmd.ExtraFiles = []*os.File{d.listener, readyW}
+	cmd.Env = append(os.Environ(),
+		listenFDEnv+"=3",
+		readyFDEnv+"=4",
+		devBuildEnv+"="+buildID,
+		envName("watch")+"=false",
+	)
+	cmd.SysProcAttr = devProcAttr()
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// The child writes to the pipe once serving; EOF without a write means it exited first.
+	if _, err := readyR.Read(make([]byte, 1)); err != nil {
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("server exited before accepting requests")
+	}
+
+	go func() {
+		d.exited <- childExit{cmd: cmd, err: cmd.Wait()}
+	}()
+	return cmd, nil
+}
+
+// stop asks the running build to shut down gracefully and waits for it.
+func (d *devServer) stop() error {
+	if d.child == nil {
+		return nil
+	}
+	_ = d.child.Process.Signal(syscall.SIGTERM)
+	var err error
+	for exit := range d.exited {
+		if exit.cmd == d.child {
+			err = exit.err
+			break
+		}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &exitError{exitErr.ExitCode(), fmt.Errorf("server exited with code %d", exitErr.ExitCode())}
+	}
+	return err
+}
+
+// watchTree adds dir and its subdirectories to the watcher, skipping hidden directories.
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// hasWatchedExtension reports whether name ends in one of the extensions.
+func hasWatchedExtension(name string, extensions []string) bool {
+	for _, ext := range extensions {
+		if ext = strings.TrimSpace(ext); ext != "" && strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutWatchFlag removes --watch (or -w) from the global arguments, so the server
+// process started by the supervisor does not become a supervisor itself.
+func withoutWatchFlag(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return append(out, args[i:]...)
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "watch" || name == "w" {
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// inheritedListener returns the listening socket passed by the dev supervisor, if any.
+func inheritedListener() (net.Listener, error) {
+	fd, err := inheritedFD(listenFDEnv)
+	if fd == nil || err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return net.FileListener(fd)
+}
+
+// notifyReady tells the dev supervisor, if any, that this process accepts requests.
+func notifyReady() {
+	fd, err := inheritedFD(readyFDEnv)
+	if fd == nil || err != nil {
+		return
+	}
+	_, _ = io.WriteString(fd, "1")
+	fd.Close()
+}
+
+// inheritedFD returns the file descriptor named by the environment variable, or nil if it is unset.
+func inheritedFD(env string) (*os.File, error) {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", env, value)
+	}
+	return os.NewFile(uintptr(fd), env), nil
+}
+
+// devReloadScript reconnects to the reload stream whenever the server restarts and
+// reloads the page once it is served by a different build.
+const devReloadScript = `(function () {
+  var build = null;
+  var source = new EventSource("` + devReloadPath + `");
+  source.addEventListener("build", function (e) {
+    if (build !== null && build !== e.data) {
+      location.reload();
+    }
+    build = e.data;
+  });
+})();`
+
+// devHeadExtras returns the reload script for pages rendered in dev mode, and nothing otherwise.
+func devHeadExtras() []nova.HTMLElement {
+	if devBuildID == "" {
+		return nil
+	}
+	return []nova.HTMLElement{nova.InlineScript(devReloadScript)}
+}
+
+// handleDevReload streams the current build ID and ends the stream when the server drains,
+// which makes the browser reconnect to the next build.
+func handleDevReload(rc *nova.ResponseContext) error {
+	w := rc.Writer()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return jsonError(rc, http.StatusInternalServerError, codeStreamingUnsupported)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 500\nevent: build\ndata: %s\n\n", devBuildID)
+	flusher.Flush()
+
+	select {
+	case <-rc.Request().Context().Done():
+	case <-drainCtx.Done():
+	}
+	return nil
+}
+
+// setupDevRoutes registers the reload stream when running under the dev supervisor.
+func setupDevRoutes(router *nova.Router) {
+	if devBuildID == "" {
+		return
+	}
+	router.GetFunc(devReloadPath, handleDevReload, &nova.RouteOptions{
+		Tags:        []string{"Development"},
+		Summary:     "Live reload stream",
+		Description: "Server-sent events carrying the build ID; only available with --watch.",
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+// devProcAttr uses the default process attributes where process groups are not available.
+func devProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// devProcAttr starts server processes in their own process group, so a Ctrl-C in the
+// terminal only reaches the dev supervisor, which then shuts the server down once.
+func devProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
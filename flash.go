@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// flashCookie carries a message from the handler that redirects to the page that shows it.
+const flashCookie = "nova_flash"
+
+// flashKeyKey stores the key that signs flash cookies in the request context.
+const flashKeyKey contextKey = "flash_key"
+
+// flashKind selects how a flash message is styled.
+type flashKind string
+
+const (
+	flashSuccess flashKind = "success"
+	flashError   flashKind = "error"
+	flashInfo    flashKind = "info"
+)
+
+// randomFlashKey signs flash cookies when session.secret is not configured. Cookies
+// signed with it do not survive a restart, which only loses a pending message.
+var randomFlashKey = func() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+// flashMiddleware makes the key that signs flash cookies available to handlers.
+// An empty secret selects a random per-process key.
+func flashMiddleware(secret string) nova.Middleware {
+	key := randomFlashKey
+	if secret != "" {
+		key = []byte(secret)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), flashKeyKey, key)))
+		})
+	}
+}
+
+// setFlash stores a message to be shown once on the next page. It must be called before
+// the response is written, typically right before rc.Redirect.
+func setFlash(rc *nova.ResponseContext, kind flashKind, message string) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(string(kind) + ":" + message))
+	http.SetCookie(rc.Writer(), &http.Cookie{
+		Name:     flashCookie,
+		Value:    payload + "." + signFlash(rc.Request().Context(), payload),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// takeFlash returns the pending flash message and clears it so that it is shown only once.
+// Cookies with an invalid signature are discarded, so a client cannot inject messages.
+func takeFlash(rc *nova.ResponseContext) (flashKind, string, bool) {
+	cookie, err := rc.Request().Cookie(flashCookie)
+	if err != nil {
+		return "", "", false
+	}
+	http.SetCookie(rc.Writer(), &http.Cookie{Name: flashCookie, Path: "/", MaxAge: -1})
+
+	payload, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signFlash(rc.Request().Context(), payload))) {
+		return "", "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", false
+	}
+	kind, message, ok := strings.Cut(string(decoded), ":")
+	switch flashKind(kind) {
+	case flashSuccess, flashError, flashInfo:
+		return flashKind(kind), message, ok
+	}
+	return "", "", false
+}
+
+// signFlash returns the signature of a flash cookie payload.
+func signFlash(ctx context.Context, payload string) string {
+	key, ok := ctx.Value(flashKeyKey).([]byte)
+	if !ok {
+		key = randomFlashKey
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// flashMessage renders the pending flash message, or nothing if there is none.
+func flashMessage(rc *nova.ResponseContext) nova.HTMLElement {
+	kind, message, ok := takeFlash(rc)
+	if !ok {
+		return nova.Text("")
+	}
+	role := "status"
+	if kind == flashError {
+		role = "alert"
+	}
+	return nova.Div().Text(message).Class("flash flash-"+string(kind)).Attr("role", role)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+func TestFlashSignature(t *testing.T) {
+	const secret = "flash-secret"
+	router := nova.NewRouter()
+	router.Use(flashMiddleware(secret))
+	router.GetFunc("/set", func(rc *nova.ResponseContext) error {
+		setFlash(rc, flashSuccess, "Item created: a.b")
+		return rc.Text(http.StatusOK, "")
+	})
+	router.GetFunc("/take", func(rc *nova.ResponseContext) error {
+		kind, message, ok := takeFlash(rc)
+		if !ok {
+			return rc.Text(http.StatusOK, "none")
+		}
+		return rc.Text(http.StatusOK, string(kind)+"|"+message)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != flashCookie {
+		t.Fatalf("cookies = %v, want one %s cookie", cookies, flashCookie)
+	}
+
+	// sign returns a cookie value for kindAndMessage signed with key
+	sign := func(key, kindAndMessage string) string {
+		payload := base64.RawURLEncoding.EncodeToString([]byte(kindAndMessage))
+		ctx := context.WithValue(context.Background(), flashKeyKey, []byte(key))
+		return payload + "." + signFlash(ctx, payload)
+	}
+	forged := base64.RawURLEncoding.EncodeToString([]byte("error:Forged"))
+	_, signature, _ := strings.Cut(cookies[0].Value, ".")
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{name: "set by the handler", cookie: cookies[0].Value, want: "success|Item created: a.b"},
+		{name: "signed with the secret", cookie: sign(secret, "info:Hello"), want: "info|Hello"},
+		{name: "no cookie", want: "none"},
+		{name: "other key", cookie: sign("other", "info:Hello"), want: "none"},
+		{name: "random key", cookie: sign(string(randomFlashKey), "info:Hello"), want: "none"},
+		{name: "swapped payload", cookie: forged + "." + signature, want: "none"},
+		{name: "unsigned", cookie: forged, want: "none"},
+		{name: "empty signature", cookie: forged + ".", want: "none"},
+		{name: "unknown kind", cookie: sign(secret, "script:Hello"), want: "none"},
+		{name: "invalid payload", cookie: sign(secret, "info:Hello")[1:], want: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/take", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: flashCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("flash = %q, want %q", got, tt.want)
+			}
+			if tt.cookie != "" {
+				if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+					t.Errorf("cookies = %v, want the flash cookie cleared", cleared)
+				}
+			}
+		})
+	}
+}
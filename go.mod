@@ -2,9 +2,10 @@ module novatest
 
 go 1.24.0
 
-require github.com/xlc-dev/nova v0.2.1
-
 require (
-	github.com/fsnotify/fsnotify v1.9.0 // indirect
-	golang.org/x/sys v0.36.0 // indirect
+	github.com/andybalholm/brotli v1.2.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/xlc-dev/nova v0.2.1
 )
+
+require golang.org/x/sys v0.36.0 // indirect
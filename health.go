@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// HealthCheckResult reports the outcome of a single health check.
+type HealthCheckResult struct {
+	Name      string  `json:"name" description:"Name of the component that was checked"`
+	Status    string  `json:"status" description:"Either ok or fail"`
+	LatencyMs float64 `json:"latencyMs" description:"Time the check took in milliseconds"`
+	Error     string  `json:"error,omitempty" description:"Reason the check failed"`
+}
+
+// HealthResponse is the body returned by the health, readiness and liveness endpoints.
+type HealthResponse struct {
+	Status string              `json:"status" description:"Either ok or fail"`
+	Phase  string              `json:"phase" description:"Lifecycle phase: starting, serving or draining"`
+	Checks []HealthCheckResult `json:"checks" description:"Individual check results"`
+}
+
+// lifecyclePhase tracks where the process is in its lifetime.
+// Only the serving phase is considered ready to receive traffic.
+type lifecyclePhase int32
+
+const (
+	phaseStarting lifecyclePhase = iota
+	phaseServing
+	phaseDraining
+)
+
+// String returns the name of the phase as reported in health responses.
+func (p lifecyclePhase) String() string {
+	switch p {
+	case phaseServing:
+		return "serving"
+	case phaseDraining:
+		return "draining"
+	default:
+		return "starting"
+	}
+}
+
+// healthCheck is a registered component check.
+type healthCheck struct {
+	name string
+	// liveness checks detect states only a restart can fix and also count towards readiness.
+	// Other checks only affect readiness, so a failing dependency takes the instance out of
+	// rotation without getting it killed.
+	liveness bool
+	check    func(ctx context.Context) error
+}
+
+// healthRegistry holds the registered checks and the lifecycle phase.
+type healthRegistry struct {
+	mu      sync.RWMutex
+	checks  []healthCheck
+	phase   atomic.Int32
+	timeout time.Duration
+}
+
+// appHealth is the registry behind /healthz, /readyz and /livez.
+var appHealth = &healthRegistry{timeout: 2 * time.Second}
+
+// register adds a named check. Components call this once during setup.
+func (h *healthRegistry) register(name string, liveness bool, check func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, healthCheck{name: name, liveness: liveness, check: check})
+}
+
+// setPhase moves the process to a new lifecycle phase.
+func (h *healthRegistry) setPhase(p lifecyclePhase) {
+	h.phase.Store(int32(p))
+}
+
+// currentPhase returns the current lifecycle phase.
+func (h *healthRegistry) currentPhase() lifecyclePhase {
+	return lifecyclePhase(h.phase.Load())
+}
+
+// run executes the selected checks concurrently, each bounded by the registry timeout.
+func (h *healthRegistry) run(ctx context.Context, livenessOnly bool) ([]HealthCheckResult, bool) {
+	h.mu.RLock()
+	checks := make([]healthCheck, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !livenessOnly || c.liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, h.timeout)
+		}()
+	}
+	wg.Wait()
+
+	healthy := true
+	for _, r := range results {
+		if r.Status != "ok" {
+			healthy = false
+		}
+	}
+	return results, healthy
+}
+
+// runCheck executes one check, converting panics and timeouts into failures.
+func runCheck(ctx context.Context, c healthCheck, timeout time.Duration) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				errCh <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		errCh <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %v", timeout)
+	}
+
+	result := HealthCheckResult{
+		Name:      c.name,
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+		slog.Warn("Health check failed", "check", c.name, "error", err)
+	}
+	return result
+}
+
+// respond writes a HealthResponse with 200 when healthy and 503 otherwise.
+func (h *healthRegistry) respond(rc *nova.ResponseContext, results []HealthCheckResult, healthy bool) error {
+	resp := HealthResponse{Status: "ok", Phase: h.currentPhase().String(), Checks: results}
+	status := http.StatusOK
+	if !healthy {
+		resp.Status = "fail"
+		status = http.StatusServiceUnavailable
+	}
+	rc.Writer().Header().Set("Cache-Control", "no-store")
+	return rc.JSON(status, resp)
+}
+
+// handleHealthz reports the result of all checks regardless of the lifecycle phase.
+func handleHealthz(rc *nova.ResponseContext) error {
+	results, healthy := appHealth.run(rc.Request().Context(), false)
+	return appHealth.respond(rc, results, healthy)
+}
+
+// handleReadyz reports whether the instance should receive traffic. It is not ready
+// while starting up or draining for shutdown, or when any check fails.
+func handleReadyz(rc *nova.ResponseContext) error {
+	results, healthy := appHealth.run(rc.Request().Context(), false)
+	if appHealth.currentPhase() != phaseServing {
+		healthy = false
+	}
+	return appHealth.respond(rc, results, healthy)
+}
+
+// handleLivez reports whether the process is alive, running only liveness checks,
+// so that a failing dependency or a graceful shutdown never triggers a restart.
+func handleLivez(rc *nova.ResponseContext) error {
+	results, healthy := appHealth.run(rc.Request().Context(), true)
+	return appHealth.respond(rc, results, healthy)
+}
+
+// storeLockPollInterval is how often the store health check retries the lock.
+const storeLockPollInterval = 5 * time.Millisecond
+
+// registerStoreHealthCheck verifies that the item store lock can be acquired,
+// which catches deadlocks that would otherwise hang every item request. The lock is
+// polled with TryLock, so a probe that gives up leaves no goroutine waiting behind it.
+// It is a readiness check only: under load the lock goes to queued waiters first, so
+// a busy but healthy instance can fail it and must not be restarted for that.
+func registerStoreHealthCheck(h *healthRegistry) {
+	h.register("item_store", false, func(ctx context.Context) error {
+		ticker := time.NewTicker(storeLockPollInterval)
+		defer ticker.Stop()
+		for !mu.TryLock() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return fmt.Errorf("store lock not acquired: %w", ctx.Err())
+			}
+		}
+		mu.Unlock()
+		return nil
+	})
+}
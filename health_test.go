@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStoreHealthCheckLeavesNoGoroutines(t *testing.T) {
+	h := &healthRegistry{timeout: 20 * time.Millisecond}
+	registerStoreHealthCheck(h)
+
+	mu.Lock()
+	before := runtime.NumGoroutine()
+	for range 5 {
+		if _, healthy := h.run(context.Background(), false); healthy {
+			mu.Unlock()
+			t.Fatal("store check passed while the lock was held")
+		}
+	}
+	if results, healthy := h.run(context.Background(), true); !healthy {
+		mu.Unlock()
+		t.Fatalf("liveness failed while the store was busy: %+v", results)
+	}
+	// Give the checks a moment to return after their deadline
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	after := runtime.NumGoroutine()
+	mu.Unlock()
+
+	if after > before {
+		t.Errorf("%d goroutines left behind by failed probes", after-before)
+	}
+	if results, healthy := h.run(context.Background(), false); !healthy {
+		t.Errorf("store check failed with the lock free: %+v", results)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// localeFiles are the message catalogs, one JSON file per language named by its tag.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// localeCookie remembers the language a visitor picked.
+const localeCookie = "nova_lang"
+
+// localeParam is the query parameter that switches the language, e.g. /items?lang=de.
+const localeParam = "lang"
+
+// localeKey stores the *locale of the request in the request context.
+const localeKey contextKey = "locale"
+
+// fallbackLocale provides the messages missing from the other catalogs.
+const fallbackLocale = "en"
+
+// pluralRule selects the plural form ("one" or "other") of a message for the count n.
+type pluralRule func(n int) string
+
+// pluralRules holds the rule of every language with a catalog.
+var pluralRules = map[string]pluralRule{
+	"en": pluralOneOther,
+	"de": pluralOneOther,
+	"fr": func(n int) string {
+		// French uses the singular for zero as well
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+}
+
+// pluralOneOther is the rule of languages that use the singular for exactly one.
+func pluralOneOther(n int) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// message is a catalog entry: either a single text or one text per plural form.
+// Texts are fmt formats, so translations may reorder arguments with %[n]d.
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+// UnmarshalJSON accepts a string or an object of plural forms.
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.forms); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms: %w", err)
+	}
+	if _, ok := m.forms["other"]; !ok {
+		return fmt.Errorf("plural message needs an \"other\" form")
+	}
+	return nil
+}
+
+// locale translates the messages of one language.
+type locale struct {
+	Tag      string
+	messages map[string]message
+	plural   pluralRule
+	fallback *locale
+}
+
+// appLocales are the languages of the embedded catalogs by tag.
+var appLocales = mustLoadLocales()
+
+// localeTags lists the tags of appLocales in sorted order.
+var localeTags = slices.Sorted(maps.Keys(appLocales))
+
+// mustLoadLocales parses the embedded catalogs. They are compiled into the binary,
+// so a malformed catalog is a programming error.
+func mustLoadLocales() map[string]*locale {
+	files, err := fs.Glob(localeFiles, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+	locales := make(map[string]*locale, len(files))
+	for _, file := range files {
+		tag := strings.TrimSuffix(path.Base(file), ".json")
+		rule, ok := pluralRules[tag]
+		if !ok {
+			panic(fmt.Sprintf("%s: no plural rule for %q", file, tag))
+		}
+		data, err := localeFiles.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+		l := &locale{Tag: tag, plural: rule}
+		if err := json.Unmarshal(data, &l.messages); err != nil {
+			panic(fmt.Sprintf("%s: %v", file, err))
+		}
+		locales[tag] = l
+	}
+	fallback, ok := locales[fallbackLocale]
+	if !ok {
+		panic("missing catalog locales/" + fallbackLocale + ".json")
+	}
+	for tag, l := range locales {
+		if tag != fallbackLocale {
+			l.fallback = fallback
+		}
+	}
+	return locales
+}
+
+// lookup finds key in this catalog or the fallback catalog, returning the locale that
+// defines it so plural forms follow the rules of the language of the text.
+func (l *locale) lookup(key string) (message, *locale, bool) {
+	for c := l; c != nil; c = c.fallback {
+		if m, ok := c.messages[key]; ok {
+			return m, c, true
+		}
+	}
+	return message{}, nil, false
+}
+
+// T translates key, formatting args into the text. Unknown keys are returned as is,
+// so a missing message is visible on the page instead of failing the request.
+func (l *locale) T(key string, args ...any) string {
+	m, _, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	if m.forms != nil {
+		return format(m.forms["other"], args)
+	}
+	return format(m.text, args)
+}
+
+// N translates key in the plural form for the count n. Like T it formats args into
+// the text, which usually include n itself.
+func (l *locale) N(key string, n int, args ...any) string {
+	m, owner, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	if m.forms == nil {
+		return format(m.text, args)
+	}
+	text, ok := m.forms[owner.plural(n)]
+	if !ok {
+		text = m.forms["other"]
+	}
+	return format(text, args)
+}
+
+// TOr translates key, or returns def when no catalog defines it.
+func (l *locale) TOr(key, def string) string {
+	if _, _, ok := l.lookup(key); !ok {
+		return def
+	}
+	return l.T(key)
+}
+
+// DateTime formats t in UTC with the date layout and month names of the language.
+func (l *locale) DateTime(t time.Time) string {
+	t = t.UTC()
+	month := l.T("date.month." + strconv.Itoa(int(t.Month())))
+	return l.T("date.datetime", t.Day(), month, t.Year(), t.Format("15:04"))
+}
+
+// Date formats the day of t with the date layout and month names of the language.
+func (l *locale) Date(t time.Time) string {
+	month := l.T("date.month." + strconv.Itoa(int(t.Month())))
+	return l.T("date.date", t.Day(), month, t.Year())
+}
+
+// format applies args to text, leaving texts without arguments untouched so a
+// literal % in them is not mistaken for a verb.
+func format(text string, args []any) string {
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// localeMiddleware picks the language of every request, see negotiateLocale, and makes
+// it available through tr for the HTML pages and the API error messages. A valid ?lang=
+// parameter is remembered in a cookie.
+func localeMiddleware(settings localeSettings) nova.Middleware {
+	def := appLocales[settings.Default]
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l, fromQuery := negotiateLocale(r, def)
+			if fromQuery {
+				http.SetCookie(w, &http.Cookie{
+					Name:     localeCookie,
+					Value:    l.Tag,
+					Path:     "/",
+					MaxAge:   365 * 24 * 60 * 60,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			if route := knownRoutes.lookup(r.URL.Path); isPageRoute(route) || isAPIRoute(route) {
+				w.Header().Set("Content-Language", l.Tag)
+				w.Header().Add("Vary", "Accept-Language")
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeKey, l)))
+		})
+	}
+}
+
+// negotiateLocale returns the language of r: the ?lang= parameter, then the cookie,
+// then the best match of Accept-Language, then def. fromQuery reports whether the
+// parameter chose it.
+func negotiateLocale(r *http.Request, def *locale) (l *locale, fromQuery bool) {
+	if l, ok := appLocales[strings.ToLower(r.URL.Query().Get(localeParam))]; ok {
+		return l, true
+	}
+	if cookie, err := r.Cookie(localeCookie); err == nil {
+		if l, ok := appLocales[cookie.Value]; ok {
+			return l, false
+		}
+	}
+	for _, tag := range acceptedLanguages(r.Header.Get("Accept-Language")) {
+		if tag == "*" {
+			return def, false
+		}
+		// A regional variant such as de-AT is served by its language, de
+		primary, _, _ := strings.Cut(tag, "-")
+		if l, ok := appLocales[primary]; ok {
+			return l, false
+		}
+	}
+	return def, false
+}
+
+// acceptedLanguages returns the lowercased tags of an Accept-Language header ordered
+// by preference. Tags with q=0 are refused and left out, and so are tags with an invalid q.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if q, ok := qValue(params); ok && q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// tr returns the language of the request, or the fallback language outside of a request.
+func tr(ctx context.Context) *locale {
+	if l, ok := ctx.Value(localeKey).(*locale); ok {
+		return l
+	}
+	return appLocales[fallbackLocale]
+}
+
+// languageSwitcher renders a link per language to the page at self in that language.
+// The links work without JavaScript; the chosen language is remembered by localeMiddleware.
+func languageSwitcher(r *http.Request, self *url.URL) nova.HTMLElement {
+	current := tr(r.Context())
+	items := make([]nova.HTMLElement, 0, len(localeTags))
+	for _, tag := range localeTags {
+		l := appLocales[tag]
+		query := self.Query()
+		query.Set(localeParam, tag)
+		link := nova.Link(self.Path+"?"+query.Encode(), l.T("locale.name")).
+			Attr("lang", tag).
+			Attr("hreflang", tag)
+		if l == current {
+			link.Attr("aria-current", "true")
+		}
+		items = append(items, nova.Li(link))
+	}
+	return nova.Nav(nova.Ul(items...)).Class("language-switcher").Attr("aria-label", current.T("footer.language"))
+}
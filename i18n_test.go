@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{name: "empty", header: "", want: []string{}},
+		{name: "ordered by weight", header: "en;q=0.5, de, fr;q=0.8", want: []string{"de", "fr", "en"}},
+		{name: "ties keep header order", header: "fr, de", want: []string{"fr", "de"}},
+		{name: "case and spaces", header: " DE-at ; Q=0.9 , en", want: []string{"en", "de-at"}},
+		{name: "q after other params", header: "de;x=1;q=0.1, fr;q=0.2", want: []string{"fr", "de"}},
+		{name: "refused", header: "de;q=0, en", want: []string{"en"}},
+		{name: "invalid weight ignored", header: "de;q=abc, en;q=0.5", want: []string{"en"}},
+		{name: "weight above one ignored", header: "de;q=2, en;q=0.5", want: []string{"en"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceptedLanguages(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("acceptedLanguages(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
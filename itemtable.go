@@ -0,0 +1,316 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// itemPageSizes are the page sizes offered by the items table; the first one is the default.
+var itemPageSizes = []int{10, 25, 50, 100}
+
+// itemSortColumns maps the sort keys accepted in the query string to the message keys
+// of their column labels.
+var itemSortColumns = []struct {
+	Key   string
+	Label string
+}{
+	{"id", "items.column.id"},
+	{"name", "items.column.name"},
+	{"priority", "items.column.priority"},
+	{"created", "items.column.created"},
+	{"updated", "items.column.updated"},
+	{"status", "items.column.status"},
+}
+
+// itemsQuery is the state of the items table. It lives entirely in the query string,
+// so every view can be bookmarked and shared and works without JavaScript.
+type itemsQuery struct {
+	Search  string   // case-insensitive substring of the name
+	Status  string   // "active", "inactive" or "" for all items
+	Tags    []string // tags every item must carry, normalized
+	Sort    string   // one of itemSortColumns
+	Desc    bool
+	Page    int // 1-based
+	PerPage int
+}
+
+// defaultItemsQuery is the table state of the items page without a query string.
+func defaultItemsQuery() itemsQuery {
+	return itemsQuery{Sort: "id", Page: 1, PerPage: itemPageSizes[0]}
+}
+
+// parseItemsQuery reads the table state from the query string. Unknown or invalid
+// values fall back to their defaults instead of failing the page.
+func parseItemsQuery(values url.Values) itemsQuery {
+	q := defaultItemsQuery()
+	q.Search = strings.TrimSpace(values.Get("q"))
+	q.Tags = normalizeTags(values["tag"])
+	if status := values.Get("status"); status == "active" || status == "inactive" {
+		q.Status = status
+	}
+	for _, c := range itemSortColumns {
+		if values.Get("sort") == c.Key {
+			q.Sort = c.Key
+		}
+	}
+	q.Desc = values.Get("dir") == "desc"
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 0 {
+		q.Page = page
+	}
+	if perPage, err := strconv.Atoi(values.Get("per_page")); err == nil && slices.Contains(itemPageSizes, perPage) {
+		q.PerPage = perPage
+	}
+	return q
+}
+
+// url returns the items page for q, leaving out parameters that have their default value.
+func (q itemsQuery) url() string {
+	values := url.Values{}
+	if q.Search != "" {
+		values.Set("q", q.Search)
+	}
+	if q.Status != "" {
+		values.Set("status", q.Status)
+	}
+	if len(q.Tags) > 0 {
+		values["tag"] = q.Tags
+	}
+	if q.Sort != "id" {
+		values.Set("sort", q.Sort)
+	}
+	if q.Desc {
+		values.Set("dir", "desc")
+	}
+	if q.Page > 1 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PerPage != itemPageSizes[0] {
+		values.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	if len(values) == 0 {
+		return "/items"
+	}
+	return "/items?" + values.Encode()
+}
+
+// apply filters and sorts items and returns the requested page of them together with
+// the number of matching items. A page past the end is clamped to the last page.
+func (q *itemsQuery) apply(items []Item) ([]Item, int) {
+	search := strings.ToLower(q.Search)
+	matched := slices.DeleteFunc(slices.Clone(items), func(item Item) bool {
+		if q.Status == "active" && !item.IsActive || q.Status == "inactive" && item.IsActive {
+			return true
+		}
+		if !hasTags(item, q.Tags) {
+			return true
+		}
+		return search != "" && !strings.Contains(strings.ToLower(item.Name), search)
+	})
+
+	slices.SortStableFunc(matched, func(a, b Item) int {
+		var c int
+		switch q.Sort {
+		case "name":
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "priority":
+			c = slices.Index(itemPriorities, a.Priority) - slices.Index(itemPriorities, b.Priority)
+		case "created":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "status":
+			// Active items first in ascending order
+			c = boolCompare(b.IsActive, a.IsActive)
+		}
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+		if q.Desc {
+			return -c
+		}
+		return c
+	})
+
+	total := len(matched)
+	q.Page = min(q.Page, max(q.pages(total), 1))
+	start := min((q.Page-1)*q.PerPage, total)
+	end := min(start+q.PerPage, total)
+	return matched[start:end], total
+}
+
+// withTag returns the first page of q narrowed down to items that also carry tag.
+func (q itemsQuery) withTag(tag string) itemsQuery {
+	q.Tags = normalizeTags(append(slices.Clone(q.Tags), tag))
+	q.Page = 1
+	return q
+}
+
+// withoutTag returns the first page of q without the filter on tag.
+func (q itemsQuery) withoutTag(tag string) itemsQuery {
+	q.Tags = slices.DeleteFunc(slices.Clone(q.Tags), func(t string) bool { return t == tag })
+	q.Page = 1
+	return q
+}
+
+// pages returns the number of pages needed to show total items.
+func (q itemsQuery) pages(total int) int {
+	return (total + q.PerPage - 1) / q.PerPage
+}
+
+// boolCompare orders false before true.
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// itemFilterBar renders the search, status and page size controls, and the active tag
+// filters with a link removing each. Submitting it resets the page to the first one
+// but keeps the tag filters and the sort order.
+func itemFilterBar(l *locale, q itemsQuery) nova.HTMLElement {
+	status := nova.Select(
+		selectOption("all", l.T("items.all_statuses"), q.Status == ""),
+		selectOption("active", l.T("status.active"), q.Status == "active"),
+		selectOption("inactive", l.T("status.inactive"), q.Status == "inactive"),
+	).Attr("name", "status").ID("status")
+
+	perPage := nova.Select().Attr("name", "per_page").ID("per_page")
+	for _, size := range itemPageSizes {
+		perPage.Add(selectOption(strconv.Itoa(size), l.T("items.per_page", size), q.PerPage == size))
+	}
+
+	search := nova.SearchInput("q").ID("q").Attr("placeholder", l.T("items.search_placeholder"))
+	if q.Search != "" {
+		search.Attr("value", q.Search)
+	}
+
+	form := nova.Form(
+		nova.Label().Text(l.T("items.search")).Attr("for", "q").Class("visually-hidden"),
+		search,
+		nova.Label().Text(l.T("items.status_filter")).Attr("for", "status").Class("visually-hidden"),
+		status,
+		nova.Label().Text(l.T("items.page_size")).Attr("for", "per_page").Class("visually-hidden"),
+		perPage,
+	).Attr("method", "GET").Attr("action", "/items").Class("filter-bar")
+	for _, tag := range q.Tags {
+		form.Add(nova.HiddenInput("tag", tag))
+	}
+	if q.Sort != "id" {
+		form.Add(nova.HiddenInput("sort", q.Sort))
+	}
+	if q.Desc {
+		form.Add(nova.HiddenInput("dir", "desc"))
+	}
+	form.Add(
+		nova.SubmitButton(l.T("items.apply")).Class("btn btn-primary"),
+		nova.Link("/items", l.T("items.reset")).Class("btn btn-secondary"),
+	)
+	if len(q.Tags) == 0 {
+		return form
+	}
+
+	active := nova.Ul().Class("tag-list")
+	for _, tag := range q.Tags {
+		active.Add(nova.Li(
+			nova.Link(q.withoutTag(tag).url(), tag+" ×").
+				Class("tag").
+				Attr("aria-label", l.T("items.remove_tag", tag)),
+		))
+	}
+	return nova.Div(form, nova.Div(nova.Span(nova.Text(l.T("items.tag_filter"))), active).Class("active-tags"))
+}
+
+// tagList renders tags as links to the items they filter, built by href.
+func tagList(tags []string, href func(tag string) string) nova.HTMLElement {
+	list := nova.Ul().Class("tag-list")
+	for _, tag := range tags {
+		list.Add(nova.Li(nova.Link(href(tag), tag).Class("tag")))
+	}
+	return list
+}
+
+// selectOption renders an <option>, selected if selected is true.
+func selectOption(value, label string, selected bool) nova.HTMLElement {
+	option := nova.Option(value, nova.Text(label))
+	if selected {
+		option.Attr("selected", "selected")
+	}
+	return option
+}
+
+// itemSortHeaders renders the header cells of the sortable columns. Clicking the
+// column the table is sorted by reverses the order; other columns sort ascending.
+func itemSortHeaders(l *locale, q itemsQuery) []nova.HTMLElement {
+	headers := make([]nova.HTMLElement, 0, len(itemSortColumns))
+	for _, c := range itemSortColumns {
+		next := q
+		next.Sort, next.Desc, next.Page = c.Key, false, 1
+		label := l.T(c.Label)
+		th := nova.Th()
+		if q.Sort == c.Key {
+			next.Desc = !q.Desc
+			if q.Desc {
+				label += " ▼"
+				th.Attr("aria-sort", "descending")
+			} else {
+				label += " ▲"
+				th.Attr("aria-sort", "ascending")
+			}
+		}
+		headers = append(headers, th.Add(nova.Link(next.url(), label).Class("sort-link")))
+	}
+	return headers
+}
+
+// itemPagination renders the page summary and the numbered page links. Long page
+// ranges are shortened to the first, last and nearby pages.
+func itemPagination(l *locale, q itemsQuery, total int) nova.HTMLElement {
+	pages := q.pages(total)
+	first := (q.Page-1)*q.PerPage + 1
+	last := min(q.Page*q.PerPage, total)
+	summary := nova.P().Text(l.N("items.showing", total, first, last, total)).Class("pagination-summary")
+	if pages <= 1 {
+		return summary
+	}
+
+	link := func(page int, label string) nova.HTMLElement {
+		next := q
+		next.Page = page
+		return nova.Li(nova.Link(next.url(), label))
+	}
+
+	list := nova.Ul().Class("pagination")
+	if q.Page > 1 {
+		list.Add(link(q.Page-1, l.T("items.prev")))
+	}
+	gap := false
+	for page := 1; page <= pages; page++ {
+		if page != 1 && page != pages && (page < q.Page-2 || page > q.Page+2) {
+			if !gap {
+				list.Add(nova.Li(nova.Span(nova.Text("…"))).Class("gap"))
+				gap = true
+			}
+			continue
+		}
+		gap = false
+		if page == q.Page {
+			list.Add(nova.Li(nova.Span(nova.Text(strconv.Itoa(page))).Attr("aria-current", "page")).Class("current"))
+			continue
+		}
+		list.Add(link(page, strconv.Itoa(page)))
+	}
+	if q.Page < pages {
+		list.Add(link(q.Page+1, l.T("items.next")))
+	}
+
+	return nova.Nav(summary, list).Attr("aria-label", l.T("items.pagination"))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// navLink is an entry of the main navigation. Label is a message key.
+type navLink struct {
+	Label string
+	Href  string
+}
+
+// mainNav lists the entries of the navigation bar shown on every page.
+var mainNav = []navLink{
+	{Label: "nav.home", Href: "/"},
+	{Label: "nav.items", Href: "/items"},
+	{Label: "nav.create", Href: "/create"},
+	{Label: "nav.docs", Href: "/docs"},
+}
+
+// crumb is a step of a page's breadcrumb trail. The current page has no Href.
+type crumb struct {
+	Label string
+	Href  string
+}
+
+// page describes an HTML page rendered inside the shared layout.
+type page struct {
+	Title string
+	// Status is the response status code, 200 when zero.
+	Status int
+	// Breadcrumbs lead from the home page to this page; none are shown when empty.
+	Breadcrumbs []crumb
+	// HeadExtras are added to <head> after the elements shared by all pages.
+	HeadExtras []nova.HTMLElement
+	// Path is the page route being rendered, which the language and theme switchers
+	// return to. It is only needed when the request went elsewhere, e.g. a form
+	// re-rendered by the API endpoint it posts to; the request URL is used when empty.
+	Path string
+}
+
+// url returns the address of p for the request r.
+func (p page) url(r *http.Request) *url.URL {
+	if p.Path == "" {
+		return r.URL
+	}
+	return &url.URL{Path: p.Path}
+}
+
+// itemsCrumbs returns the breadcrumb trail of the items list followed by trail.
+func itemsCrumbs(l *locale, trail ...crumb) []crumb {
+	return append([]crumb{{Label: l.T("nav.home"), Href: "/"}, {Label: l.T("nav.items"), Href: "/items"}}, trail...)
+}
+
+// renderPage responds with content inside the shared layout: the header with the
+// navigation, breadcrumbs, the pending flash message and the footer.
+func renderPage(rc *nova.ResponseContext, p page, content ...nova.HTMLElement) error {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// Every head element gets the CSP nonce, so page-specific inline scripts and styles work too
+	l := tr(rc.Request().Context())
+	head := append(append(pageHeadExtras(), themeHeadExtras(rc.Request())...), p.HeadExtras...)
+	body := append([]nova.HTMLElement{breadcrumbs(l, p.Breadcrumbs), flashMessage(rc)}, content...)
+	doc := nova.Document(
+		nova.DocumentConfig{
+			Lang:       l.Tag,
+			Title:      p.Title,
+			HeadExtras: withNonce(rc.Request().Context(), head),
+		},
+		appHeader(rc),
+		nova.Main(
+			nova.Section(
+				nova.Div(body...).Class("container"),
+			).Class("content-section"),
+		).Class("container"),
+		appFooter(rc, p.url(rc.Request())),
+	)
+	return rc.HTML(status, doc)
+}
+
+// pageHeadExtras returns the <head> elements shared by all HTML pages,
+// including the live reload script when running under --watch.
+func pageHeadExtras() []nova.HTMLElement {
+	extras := []nova.HTMLElement{
+		nova.Favicon(assetURL("favicon.png")),
+		nova.StyleSheet(assetURL("themes.css")),
+		nova.StyleSheet(assetURL("app.css")),
+		nova.InlineScript(localTimeScript),
+	}
+	return append(extras, devHeadExtras()...)
+}
+
+// appHeader renders the page header shared by all HTML pages.
+// Requests for a tenant other than the default one show the tenant next to the logo.
+func appHeader(rc *nova.ResponseContext) nova.HTMLElement {
+	header := nova.Header(
+		nova.A("/", nova.Text("Nova"), nova.Span(nova.Text("App"))).Class("logo"),
+	).Class("app-header")
+	l := tr(rc.Request().Context())
+	if tenant := tenantFrom(rc.Request().Context()); tenant != defaultTenant {
+		header.Add(nova.Div().Text(l.T("header.tenant", tenant)).Class("tenant-badge"))
+	}
+	header.Add(mainNavigation(l, rc.Request().URL.Path))
+	return header
+}
+
+// mainNavigation renders the navigation bar, highlighting the entry of the section
+// that path belongs to, e.g. "Items" on /items/1/edit.
+func mainNavigation(l *locale, path string) nova.HTMLElement {
+	active := ""
+	for _, n := range mainNav {
+		// The home page is only active on itself, not on every path below it
+		inSection := path == n.Href || (n.Href != "/" && strings.HasPrefix(path, n.Href+"/"))
+		if inSection && len(n.Href) > len(active) {
+			active = n.Href
+		}
+	}
+
+	items := make([]nova.HTMLElement, 0, len(mainNav))
+	for _, n := range mainNav {
+		link := nova.Link(n.Href, l.T(n.Label))
+		if n.Href == active {
+			link.Class("active").Attr("aria-current", "page")
+		}
+		items = append(items, nova.Li(link))
+	}
+	return nova.Nav(nova.Ul(items...)).Class("main-nav")
+}
+
+// breadcrumbs renders the breadcrumb trail, or nothing for an empty trail.
+func breadcrumbs(l *locale, trail []crumb) nova.HTMLElement {
+	if len(trail) == 0 {
+		return nova.Text("")
+	}
+	items := make([]nova.HTMLElement, 0, len(trail))
+	for _, c := range trail {
+		if c.Href == "" {
+			items = append(items, nova.Li(nova.Span(nova.Text(c.Label)).Attr("aria-current", "page")))
+			continue
+		}
+		items = append(items, nova.Li(nova.Link(c.Href, c.Label)))
+	}
+	return nova.Nav(nova.Ol(items...)).Class("breadcrumbs").Attr("aria-label", l.T("breadcrumb.label"))
+}
+
+// appFooter renders the page footer shared by all HTML pages, including the language
+// and theme switchers. self is the address of the page, see page.url.
+func appFooter(rc *nova.ResponseContext, self *url.URL) nova.HTMLElement {
+	l := tr(rc.Request().Context())
+	return nova.Footer(
+		nova.P(
+			nova.Text(l.T("footer.built_with")+" "),
+			nova.Link("https://github.com/xlc-dev/nova", "Nova"),
+			nova.Text(" · "),
+			nova.Link("/docs", l.T("nav.docs")),
+			nova.Text(" · "),
+			nova.Link("/openapi.json", "OpenAPI"),
+		),
+		languageSwitcher(rc.Request(), self),
+		themeSwitcher(rc.Request(), self),
+	).Class("app-footer")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// loggerKey stores the *requestLogger in the request context.
+const loggerKey contextKey = "logger"
+
+// requestLogger holds the logger of one request. It is stored by pointer so that
+// attributes added further down the chain also reach the access log line.
+type requestLogger struct {
+	logger *slog.Logger
+}
+
+// requestLogMiddleware attaches a logger carrying the request ID and route pattern
+// to the request context, so every log line written while handling the request can
+// be correlated. When enabled, it also logs each completed request at a level
+// derived from its status code.
+func requestLogMiddleware(settings requestLogSettings) nova.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			// The default logger is read per request so that a reload of the log settings applies immediately.
+			// Nova runs middlewares for matched routes only, so the route is always known.
+			logger := slog.Default().With("route", knownRoutes.lookup(r.URL.Path))
+			if id := nova.GetRequestID(r.Context()); settings.IncludeRequestID && id != "unknown" {
+				logger = logger.With("request_id", id)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), loggerKey, &requestLogger{logger: logger}))
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if !settings.Enabled {
+				return
+			}
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case rec.status >= 500:
+				level = slog.LevelError
+			case rec.status >= 400:
+				level = slog.LevelWarn
+			}
+			loggerFrom(r.Context()).LogAttrs(r.Context(), level, "Request completed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Int64("size", rec.size),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+// recoveryMiddleware turns a panic in a handler into a 500 response and logs it
+// with the stack trace through the request logger.
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				loggerFrom(r.Context()).Error("Recovered from panic",
+					"panic", fmt.Sprint(p),
+					"stack", string(debug.Stack()),
+				)
+				if w.Header().Get("Content-Type") == "" {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// addLogAttrs adds attributes to the request logger for the rest of the request.
+func addLogAttrs(ctx context.Context, args ...any) {
+	if l, ok := ctx.Value(loggerKey).(*requestLogger); ok {
+		l.logger = l.logger.With(args...)
+	}
+}
+
+// loggerFrom returns the request-scoped logger, or the default logger outside of a request.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey).(*requestLogger); ok {
+		return l.logger
+	}
+	return slog.Default()
+}
+
+// configureSlog sets the default slog logger from the log format and level settings.
+// Output of the standard log package, including nova's own, is routed through it as well.
+func configureSlog(format, level string) {
+	opts := &slog.HandlerOptions{}
+	switch strings.ToLower(level) {
+	case "debug":
+		opts.Level = slog.LevelDebug
+	case "warn", "warning":
+		opts.Level = slog.LevelWarn
+	case "error":
+		opts.Level = slog.LevelError
+	default:
+		opts.Level = slog.LevelInfo
+	}
+
+	var handler slog.Handler
+	if strings.ToLower(format) == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"embed"
+	"errors"
 	"fmt"
-	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,361 +22,224 @@ import (
 // Item represents an individual item in our API with metadata about creation and status.
 // This is the main data structure exposed through both JSON API and HTML interfaces.
 type Item struct {
-	ID        int       `json:"id" description:"Unique identifier for the item"`
-	Name      string    `json:"name" minlength:"3" maxlength:"10" format:"alpha"`
-	CreatedAt time.Time `json:"createdAt" description:"Timestamp when the item was created"`
-	IsActive  bool      `json:"isActive,omitempty" description:"Indicates if the item is active"`
+	ID          int       `json:"id" description:"Unique identifier for the item"`
+	Name        string    `json:"name" minlength:"3" maxlength:"10" format:"alpha"`
+	Description string    `json:"description,omitempty" maxlength:"2000" description:"Longer description in Markdown"`
+	Tags        []string  `json:"tags" maxItems:"10" maxlength:"20" pattern:"^[\\p{L}\\p{N}_-]+$" description:"Lowercase tags, sorted and without duplicates"`
+	Priority    string    `json:"priority" enum:"low|medium|high" description:"Priority of the item"`
+	CreatedAt   time.Time `json:"createdAt" description:"Timestamp when the item was created"`
+	UpdatedAt   time.Time `json:"updatedAt" description:"Timestamp of the last change to the item"`
+	IsActive    bool      `json:"isActive,omitempty" description:"Indicates if the item is active"`
+	// CustomFields holds the values of the custom fields of the tenant, see customfields.go
+	CustomFields map[string]any `json:"customFields,omitempty" description:"Values of the custom fields defined for the tenant"`
 }
 
 // NewItemInput represents the data structure clients send when creating new items.
 // It contains only the fields that can be set during creation, excluding auto-generated fields.
 type NewItemInput struct {
-	Name     string `json:"name" description:"Name for the new item" minlength:"3" maxlength:"10" format:"alpha"`
-	IsActive bool   `json:"isActive,omitempty" description:"Initial active status"`
+	Name        string   `json:"name" description:"Name for the new item" minlength:"3" maxlength:"10" format:"alpha"`
+	Description string   `json:"description,omitempty" description:"Longer description in Markdown" maxlength:"2000"`
+	Tags        []string `json:"tags,omitempty" description:"Tags of the item; forms send them comma-separated" maxItems:"10" maxlength:"20" pattern:"^[\\p{L}\\p{N}_-]+$"`
+	Priority    string   `json:"priority,omitempty" description:"Priority of the item, medium if not given" enum:"low|medium|high"`
+	IsActive    bool     `json:"isActive,omitempty" description:"Initial active status"`
+	// CustomFields is checked against the definitions of the tenant by bindItemInput
+	CustomFields map[string]any `json:"customFields,omitempty" description:"Values of the custom fields defined for the tenant; forms send them as customFields.<name>"`
 }
 
-// ErrorResponse provides a standardized JSON error response format.
-// This ensures consistent error reporting across all API endpoints.
-type ErrorResponse struct {
-	Error string `json:"error" description:"Description of the error"`
-}
-
-// Global variables for simple in-memory storage
-var (
-	// items stores all items in memory using their ID as the key.
-	items = make(map[int]Item)
-	// nextItemID tracks the next available ID for new items.
-	nextItemID = 0
-	// mu protects concurrent access to the items map and nextItemID counter.
-	mu sync.Mutex
-)
-
-//go:embed static/*
-var staticFiles embed.FS
-
-// getCommonStyles returns CSS styles used across multiple pages.
-// This centralizes styling to maintain consistency across the application.
-func getCommonStyles() string {
-	return `
-		:root {
-			--primary-color: #f9a825;
-			--primary-light: #ffcc66;
-			--secondary-color: #87ceeb;
-			--bg-color: #0a0f2a;
-			--bg-gradient-end: #2a1a40;
-			--text-color: #f0e6d2;
-			--heading-color: #ffffff;
-			--subtle-text: #cccccc;
-			--subtle-bg: #101535;
-			--border-color: #333858;
-			--card-shadow: 0 4px 15px rgba(0, 0, 0, 0.4);
-			--button-shadow: 0 2px 5px rgba(0, 0, 0, 0.3);
-			--code-bg: #1e222c;
-			--border-anim-speed: 2s;
-		}
-
-		*,
-		*::before,
-		*::after {
-			box-sizing: border-box;
-			margin: 0;
-			padding: 0;
-		}
-
-		html {
-			font-size: 16px;
-			scroll-behavior: smooth;
-			scroll-padding-top: 4rem;
-		}
-
-		body {
-			font-family: "Inter", -apple-system, BlinkMacSystemFont, "Segoe UI",
-				Roboto, "Helvetica Neue", Arial, sans-serif;
-			line-height: 1.7;
-			color: var(--text-color);
-			background-color: var(--bg-color);
-			background-image: linear-gradient(
-				90deg,
-				var(--bg-color) 0%,
-				var(--bg-gradient-end) 100%
-			);
-			overflow-x: hidden;
-		}
-
-		.container {
-			max-width: 1140px;
-			width: 90%;
-			margin: 0 auto;
-			padding: 0 1rem;
-		}
-
-		.app-header {
-			background-color: rgba(10, 15, 42, 0.8);
-			backdrop-filter: blur(10px);
-			border-bottom: 1px solid var(--border-color);
-			padding: 1.5rem 0;
-			margin-bottom: 2rem;
-			text-align: center;
-		}
-
-		.app-header .logo {
-			font-size: 1.8rem;
-			font-weight: 700;
-			color: var(--primary-light);
-			text-decoration: none;
-			display: inline-block;
-		}
-
-		.app-header .logo span {
-			color: var(--secondary-color);
-		}
-
-		.main-nav ul {
-			list-style: none;
-			display: flex;
-			justify-content: center;
-			gap: 1.5rem;
-			margin-top: 0.5rem;
-		}
-
-		.main-nav a {
-			color: var(--text-color);
-			text-decoration: none;
-			font-weight: 500;
-			transition: color 0.3s ease;
-		}
-
-		.main-nav a:hover, .main-nav a.active {
-			color: var(--primary-color);
-		}
-
-		.btn {
-			display: inline-block;
-			padding: 0.8rem 1.8rem;
-			border-radius: 50px;
-			text-decoration: none;
-			font-weight: 600;
-			font-size: 1rem;
-			transition: all 0.3s ease;
-			cursor: pointer;
-			border: none;
-			box-shadow: var(--button-shadow);
-			margin: 0.25rem;
-		}
-
-		.btn-primary {
-			background-color: var(--primary-color);
-			color: var(--bg-color);
-		}
-
-		.btn-primary:hover {
-			background-color: var(--primary-light);
-			transform: translateY(-2px);
-			box-shadow: 0 4px 8px rgba(0, 0, 0, 0.3);
-		}
+// itemPriorities are the priorities of an item from lowest to highest.
+var itemPriorities = []string{"low", "medium", "high"}
 
-		.btn-secondary {
-			background-color: transparent;
-			color: var(--primary-light);
-			border: 1px solid var(--primary-light);
-		}
-
-		.btn-secondary:hover {
-			background-color: rgba(249, 168, 37, 0.1);
-			transform: translateY(-2px);
-			box-shadow: 0 4px 8px rgba(0, 0, 0, 0.2);
-		}
-
-		.content-section {
-			padding: 3rem 0;
-			color: var(--heading-color);
-		}
-
-		.content-section .container {
-			position: relative;
-			z-index: 2;
-		}
-
-		.content-section h1, .content-section h2 {
-			font-weight: 700;
-			color: var(--heading-color);
-			margin-bottom: 1.5rem;
-			line-height: 1.3;
-			text-align: center;
-		}
+// defaultPriority is the priority of items created without one.
+const defaultPriority = "medium"
 
-		.content-section h1 {
-			font-size: 2.8rem;
-		}
-		.content-section h2 {
-			font-size: 2.2rem;
-		}
-
-		.content-section h1 span {
-			color: var(--primary-color);
-		}
+// normalize trims the description and turns the tags into a set, dropping the empty
+// entries and duplicates the comma-separated form field easily produces.
+func (in *NewItemInput) normalize() {
+	in.Description = strings.TrimSpace(in.Description)
+	in.Tags = normalizeTags(in.Tags)
+}
 
-		.content-section p, .content-section ul {
-			font-size: 1.1rem;
-			color: var(--text-color);
-			max-width: 700px;
-			margin: 0 auto 2rem auto;
-			text-align: center;
+// normalizeTags returns tags as a sorted set of trimmed, lowercased tags.
+func normalizeTags(tags []string) []string {
+	set := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			set = append(set, tag)
 		}
+	}
+	slices.Sort(set)
+	return slices.Compact(set)
+}
 
-		.content-section ul {
-			list-style-position: inside;
-			padding-left: 0;
-		}
-		.content-section ul li {
-			margin-bottom: 0.5rem;
+// hasTags reports whether item carries every tag of tags.
+func hasTags(item Item, tags []string) bool {
+	for _, tag := range tags {
+		if _, found := slices.BinarySearch(item.Tags, tag); !found {
+			return false
 		}
+	}
+	return true
+}
 
-		.cta-buttons {
-			display: flex;
-			justify-content: center;
-			gap: 1rem;
-			margin-top: 2rem;
-		}
+// itemStore holds the items of a single tenant together with its own ID sequence,
+// so IDs are never shared or leaked between tenants.
+type itemStore struct {
+	// items stores all items of the tenant using their ID as the key.
+	items map[int]Item
+	// nextItemID tracks the next available ID for new items of the tenant.
+	nextItemID int
+	// fields are the custom field definitions of the tenant in the order they were added.
+	fields []CustomField
+}
 
-		.table {
-			width: 100%;
-			border-collapse: collapse;
-			margin: 2rem 0;
-			background-color: var(--subtle-bg);
-			border: 1px solid var(--border-color);
-			border-radius: 8px;
-			overflow: hidden;
-			box-shadow: var(--card-shadow);
-		}
-		.table th, .table td {
-			padding: 1rem;
-			text-align: left;
-			border-bottom: 1px solid var(--border-color);
-			color: var(--text-color);
-		}
-		.table th {
-			background-color: var(--bg-color);
-			color: var(--primary-light);
-			font-weight: 600;
-		}
-		.table tr:last-child td {
-			border-bottom: none;
-		}
-		.table tr:hover td {
-			background-color: var(--subtle-bg);
-		}
-		.table .btn {
-			padding: 0.4rem 0.9rem;
-			font-size: 0.9rem;
-		}
+// Global variables for simple in-memory storage
+var (
+	// stores holds one item store per tenant, keyed by tenant ID.
+	stores = make(map[string]*itemStore)
+	// mu protects concurrent access to the stores map and every itemStore in it.
+	mu sync.Mutex
+)
 
-		.form-group {
-			margin-bottom: 1.5rem;
-		}
-		.form-group label {
-			display: block;
-			margin-bottom: 0.5rem;
-			font-weight: 500;
-			color: var(--primary-light);
-		}
-		.form-group input[type="text"],
-		.form-group input[type="checkbox"],
-		.form-group textarea {
-			width: 100%;
-			padding: 0.75rem;
-			border: 1px solid var(--border-color);
-			border-radius: 4px;
-			box-sizing: border-box;
-			background-color: var(--subtle-bg);
-			color: var(--text-color);
-			font-size: 1rem;
-		}
-		.form-group input[type="text"]:focus,
-		.form-group textarea:focus {
-			outline: none;
-			border-color: var(--primary-color);
-			box-shadow: 0 0 0 2px rgba(249, 168, 37, 0.3);
-		}
-		.form-group input[type="checkbox"] {
-			width: auto;
-			margin-right: 0.5rem;
-			vertical-align: middle;
-		}
-		.form-actions {
-			margin-top: 2rem;
-			display: flex;
-			gap: 1rem;
-		}
+// emptyStore stands in for the store of a tenant that has none yet. It is never
+// written to, since it has no items to change and no fields to remove.
+var emptyStore = &itemStore{}
+
+// readStore returns the item store of the given tenant, or emptyStore if it has none.
+// Unlike storeFor it never creates a store, so requests naming made-up tenants cannot
+// grow the stores map. Handlers that only read or change existing items use it.
+// The caller must hold mu.
+func readStore(tenant string) *itemStore {
+	if store, ok := stores[tenant]; ok {
+		return store
+	}
+	return emptyStore
+}
 
-		@media (max-width: 768px) {
-			html {
-				font-size: 15px;
-			}
-			.content-section h1 {
-				font-size: 2.2rem;
-			}
-			.content-section h2 {
-				font-size: 1.8rem;
-			}
-			.content-section p, .content-section ul {
-				font-size: 1rem;
-			}
-			.cta-buttons {
-				flex-direction: column;
-				align-items: center;
-			}
-			.btn {
-				width: 80%;
-				max-width: 300px;
-				text-align: center;
-			}
-			.form-actions {
-				flex-direction: column;
-			}
-			.form-actions .btn {
-				width: 100%;
-			}
-		}
-	`
+// storeFor returns the item store of the given tenant, creating it on first use.
+// Only handlers adding items or fields use it. The caller must hold mu.
+func storeFor(tenant string) *itemStore {
+	store, ok := stores[tenant]
+	if !ok {
+		store = &itemStore{items: make(map[int]Item)}
+		stores[tenant] = store
+	}
+	return store
 }
 
+//go:embed static
+var staticFiles embed.FS
+
 // setupRoutes configures all application routes including both HTML pages and JSON API endpoints.
 // It demonstrates the dual nature of the Nova framework supporting both web pages and API responses.
 func setupRoutes(router *nova.Router) {
-	// Serve static files from the "static" directory
-	staticFS, _ := fs.Sub(staticFiles, "static")
-	router.Static("/static", staticFS)
+	// Serve the embedded static files, fingerprinted and precompressed, see assets.go
+	router.GetFunc("/static/{file}", handleAsset, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Static asset",
+		Description: "Serves an embedded asset. Fingerprinted names are cached immutably; brotli or gzip is used when accepted.",
+	})
+	router.HandleFunc(http.MethodHead, "/static/{file}", handleAsset)
 	setupHTMLRoutes(router)
 	setupAPIRoutes(router)
+	setupMonitoringRoutes(router)
 	setupDocumentationRoutes(router)
+	setupDevRoutes(router)
+
+	// Route patterns are resolved for metrics only after everything is registered
+	knownRoutes.load(router)
+
+	// Item changes purge the cached pages and API responses of their tenant
+	onItemsChanged(appResponseCache.invalidate)
 }
 
 // setupHTMLRoutes configures routes that return HTML responses for web browser consumption.
 // These routes demonstrate the HTML builder capabilities of the Nova framework.
 func setupHTMLRoutes(router *nova.Router) {
 	// Home page with navigation and feature overview
-	router.GetFunc("/", handleHomePage, &nova.RouteOptions{
+	router.GetFunc(pageRoutes.add("/"), handleHomePage, &nova.RouteOptions{
 		Tags:        []string{"General"},
 		Summary:     "Home page",
 		Description: "Returns the main HTML page with navigation and feature overview.",
 	})
 
 	// Items list page showing all items in a table format
-	router.GetFunc("/items", handleItemsListPage, &nova.RouteOptions{
+	router.GetFunc(pageRoutes.add("/items"), handleItemsListPage, &nova.RouteOptions{
 		Tags:        []string{"General"},
 		Summary:     "Items list page",
-		Description: "Returns an HTML page showing all items in a table format.",
+		Description: "Returns an HTML page showing all items in a table format, searched, filtered, sorted and paginated by the query parameters.",
+		Parameters: []nova.ParameterOption{
+			{Name: "q", In: "query", Description: "Case-insensitive search in item names", Schema: ""},
+			{Name: "status", In: "query", Description: "Filter by status: active, inactive or all", Schema: ""},
+			{Name: "sort", In: "query", Description: "Sort column: id, name, created or status", Schema: ""},
+			{Name: "dir", In: "query", Description: "Sort direction: asc or desc", Schema: ""},
+			{Name: "page", In: "query", Description: "Page number, starting at 1", Schema: int(0)},
+			{Name: "per_page", In: "query", Description: "Items per page: 10, 25, 50 or 100", Schema: int(0)},
+		},
+	})
+
+	// Item detail page with all fields and actions
+	router.GetFunc(pageRoutes.add("/items/{itemId}"), handleItemDetailPage, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Item detail page",
+		Description: "Returns an HTML page showing all fields of an item, or an HTML 404 page if it does not exist.",
+		Parameters:  itemIDParameter("The ID of the item to show"),
+	})
+
+	// Delete confirmation page, submitting a DELETE through the method override
+	router.GetFunc(pageRoutes.add("/items/{itemId}/delete"), handleDeleteItemPage, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Delete item page",
+		Description: "Returns an HTML page asking to confirm the deletion of an item.",
+		Parameters:  itemIDParameter("The ID of the item to delete"),
+	})
+
+	// Edit form for an existing item, posting back to the same URL
+	router.GetFunc(pageRoutes.add("/items/{itemId}/edit"), handleEditItemPage, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Edit item page",
+		Description: "Returns an HTML form pre-filled with the item's current values.",
+		Parameters:  itemIDParameter("The ID of the item to edit"),
+	})
+	router.PostFunc(pageRoutes.add("/items/{itemId}/edit"), handleUpdateItem, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Submit item changes",
+		Description: "Updates the item from form data and redirects to its detail page, or re-renders the form with errors.",
+		Parameters:  itemIDParameter("The ID of the item to update"),
+		RequestBody: &NewItemInput{},
+	})
+
+	// Violation reports sent by browsers for the Content-Security-Policy of the pages
+	router.PostFunc(cspReportPath, handleCSPReport, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "CSP violation report",
+		Description: "Logs Content-Security-Policy violations reported by browsers, in the report-uri or Reporting API format.",
+	})
+
+	// Theme switcher submitted from the footer of every page
+	router.PostFunc(pageRoutes.add("/theme"), handleSetTheme, &nova.RouteOptions{
+		Tags:        []string{"General"},
+		Summary:     "Change the theme",
+		Description: "Remembers the theme picked in the page footer in a cookie and redirects back to the page.",
 	})
 
 	// Create item form page for adding new items
-	router.GetFunc("/create", handleCreateItemPage, &nova.RouteOptions{
+	router.GetFunc(pageRoutes.add("/create"), handleCreateItemPage, &nova.RouteOptions{
 		Tags:        []string{"General"},
 		Summary:     "Create item page",
 		Description: "Returns an HTML form for creating new items.",
 	})
 }
 
+// itemIDParameter documents the {itemId} path parameter of item routes.
+func itemIDParameter(description string) []nova.ParameterOption {
+	return []nova.ParameterOption{{
+		Name:        "itemId",
+		In:          "path",
+		Description: description,
+		Schema:      int(0),
+	}}
+}
+
 // setupAPIRoutes configures JSON API endpoints with reduced boilerplate using enhanced handlers.
 // These routes demonstrate clean JSON API development with automatic response handling.
 func setupAPIRoutes(router *nova.Router) {
@@ -381,7 +249,10 @@ func setupAPIRoutes(router *nova.Router) {
 	api.GetFunc("/items", handleGetItems, &nova.RouteOptions{
 		Tags:        []string{"Items"},
 		Summary:     "List all items",
-		Description: "Retrieves a list of all items in the system.",
+		Description: "Retrieves a list of all items in the system, optionally only the ones carrying every given tag.",
+		Parameters: []nova.ParameterOption{
+			{Name: "tag", In: "query", Description: "Only list items with this tag; repeat to require several tags", Schema: ""},
+		},
 		Responses: map[int]nova.ResponseOption{
 			http.StatusOK: {Description: "List of items", Body: []Item{}},
 		},
@@ -414,8 +285,26 @@ func setupAPIRoutes(router *nova.Router) {
 		OperationID: "createItem",
 		RequestBody: &NewItemInput{},
 		Responses: map[int]nova.ResponseOption{
-			http.StatusCreated:    {Description: "Item created successfully", Body: &Item{}},
-			http.StatusBadRequest: {Description: "Invalid input", Body: &ErrorResponse{}},
+			http.StatusCreated:               {Description: "Item created successfully", Body: &Item{}},
+			http.StatusBadRequest:            {Description: "Invalid input", Body: &ErrorResponse{}},
+			http.StatusRequestEntityTooLarge: {Description: "Request body over 1 MiB", Body: &ErrorResponse{}},
+			http.StatusTooManyRequests:       {Description: "Rate limit exceeded", Body: &ProblemResponse{}},
+		},
+	})
+
+	// Update item by ID, accepting the same input as creation
+	api.PutFunc("/items/{itemId}", handleUpdateItem, &nova.RouteOptions{
+		Tags:        []string{"Items"},
+		Summary:     "Update an item",
+		Description: "Replaces the name, description, tags, priority, status and custom fields of an item. Supports both JSON and form data input.",
+		OperationID: "updateItem",
+		Parameters:  itemIDParameter("The ID of the item to update"),
+		RequestBody: &NewItemInput{},
+		Responses: map[int]nova.ResponseOption{
+			http.StatusOK:                    {Description: "Item updated successfully", Body: &Item{}},
+			http.StatusBadRequest:            {Description: "Invalid item ID or input", Body: &ErrorResponse{}},
+			http.StatusRequestEntityTooLarge: {Description: "Request body over 1 MiB", Body: &ErrorResponse{}},
+			http.StatusNotFound:              {Description: "Item not found", Body: &ErrorResponse{}},
 		},
 	})
 
@@ -423,7 +312,7 @@ func setupAPIRoutes(router *nova.Router) {
 	api.DeleteFunc("/items/{itemId}", handleDeleteItem, &nova.RouteOptions{
 		Tags:        []string{"Items"},
 		Summary:     "Delete an item",
-		Description: "Removes an item from the collection using its unique identifier.",
+		Description: "Removes an item from the collection using its unique identifier. HTML forms can call it as a POST with _method=DELETE and are redirected to the items list.",
 		OperationID: "deleteItem",
 		Parameters: []nova.ParameterOption{{
 			Name:        "itemId",
@@ -433,10 +322,56 @@ func setupAPIRoutes(router *nova.Router) {
 		}},
 		Responses: map[int]nova.ResponseOption{
 			http.StatusOK:         {Description: "Item deleted successfully"},
+			http.StatusSeeOther:   {Description: "Form submission redirected to the items list"},
 			http.StatusBadRequest: {Description: "Invalid item ID", Body: &ErrorResponse{}},
 			http.StatusNotFound:   {Description: "Item not found", Body: &ErrorResponse{}},
 		},
 	})
+
+	setupCustomFieldRoutes(api)
+}
+
+// setupMonitoringRoutes configures endpoints used by monitoring systems.
+// These routes expose operational data rather than application content.
+func setupMonitoringRoutes(router *nova.Router) {
+	registerItemGauges(appMetrics)
+	registerStoreHealthCheck(appHealth)
+
+	healthResponses := map[int]nova.ResponseOption{
+		http.StatusOK:                 {Description: "All checks passed", Body: &HealthResponse{}},
+		http.StatusServiceUnavailable: {Description: "At least one check failed", Body: &HealthResponse{}},
+	}
+
+	// Overall health including every registered check
+	router.GetFunc("/healthz", handleHealthz, &nova.RouteOptions{
+		Tags:        []string{"Monitoring"},
+		Summary:     "Health check",
+		Description: "Runs all registered component checks and reports their status and latency.",
+		Responses:   healthResponses,
+	})
+
+	// Readiness for load balancers and orchestrators
+	router.GetFunc("/readyz", handleReadyz, &nova.RouteOptions{
+		Tags:        []string{"Monitoring"},
+		Summary:     "Readiness check",
+		Description: "Reports not ready while the server is starting up or draining, or when a check fails.",
+		Responses:   healthResponses,
+	})
+
+	// Liveness for restart decisions
+	router.GetFunc("/livez", handleLivez, &nova.RouteOptions{
+		Tags:        []string{"Monitoring"},
+		Summary:     "Liveness check",
+		Description: "Runs only liveness checks; failing this check means the process should be restarted.",
+		Responses:   healthResponses,
+	})
+
+	// Prometheus scrape endpoint
+	router.GetFunc("/metrics", handleMetrics, &nova.RouteOptions{
+		Tags:        []string{"Monitoring"},
+		Summary:     "Prometheus metrics",
+		Description: "Returns request counters, latency histograms and item gauges in the Prometheus text exposition format.",
+	})
 }
 
 // setupDocumentationRoutes configures routes for API documentation and error demonstration.
@@ -444,15 +379,15 @@ func setupAPIRoutes(router *nova.Router) {
 func setupDocumentationRoutes(router *nova.Router) {
 	// Error demonstration endpoint - single line error response
 	router.GetFunc("/error", func(rc *nova.ResponseContext) error {
-		return rc.JSONError(500, "This is a demonstration error!")
+		return jsonError(rc, http.StatusInternalServerError, codeDemoError)
 	}, &nova.RouteOptions{
 		Tags:        []string{"General"},
 		Summary:     "Error demonstration",
 		Description: "Demonstrates error handling and response formatting.",
 	})
 
-	// OpenAPI specification endpoint
-	router.ServeOpenAPISpec("/openapi.json", nova.OpenAPIConfig{
+	// OpenAPI specification endpoint, documenting the validation rules of the schemas
+	serveOpenAPISpec(router, "/openapi.json", nova.OpenAPIConfig{
 		Title:       "Nova API with HTML & JSON",
 		Version:     "1.0.0",
 		Description: "This is an example API built with Nova that supports both HTML and JSON responses.",
@@ -462,137 +397,193 @@ func setupDocumentationRoutes(router *nova.Router) {
 	router.ServeSwaggerUI("/docs")
 }
 
+// localTimeScript rewrites every <time class="local-time"> into the browser's time zone,
+// formatted for the language of the page. Without JavaScript the server-rendered UTC time stays visible.
+const localTimeScript = `document.addEventListener("DOMContentLoaded", function () {
+  document.querySelectorAll("time.local-time").forEach(function (el) {
+    var d = new Date(el.getAttribute("datetime"));
+    if (!isNaN(d)) {
+      el.title = el.textContent;
+      el.textContent = d.toLocaleString(document.documentElement.lang || undefined, { dateStyle: "medium", timeStyle: "short" });
+    }
+  });
+});`
+
+// localTime renders t in UTC in the format of l, to be shown in the user's time zone by localTimeScript.
+func localTime(l *locale, t time.Time) nova.HTMLElement {
+	return nova.TimeEl(nova.Text(l.DateTime(t))).
+		Attr("datetime", t.UTC().Format(time.RFC3339)).
+		Class("local-time")
+}
+
+// statusBadge renders the active state of an item.
+func statusBadge(l *locale, active bool) nova.HTMLElement {
+	if active {
+		return nova.Span(nova.Text(l.T("status.active"))).Class("status-badge status-active")
+	}
+	return nova.Span(nova.Text(l.T("status.inactive"))).Class("status-badge status-inactive")
+}
+
+// priorityBadge renders the priority of an item.
+func priorityBadge(l *locale, priority string) nova.HTMLElement {
+	return nova.Span(nova.Text(l.T("priority." + priority))).Class("priority-badge priority-" + priority)
+}
+
 // handleHomePage renders the main application homepage with navigation and feature overview.
 // It demonstrates the HTML builder pattern for creating complete web pages.
 func handleHomePage(rc *nova.ResponseContext) error {
-	doc := nova.Document(
-		nova.DocumentConfig{
-			Title: "Nova App",
-			HeadExtras: []nova.HTMLElement{
-				nova.Favicon("/static/favicon.png"),
-				nova.StyleTag(getCommonStyles()),
-			},
-		},
-		nova.Header(
-			nova.A("/", nova.Text("Nova"), nova.Span(nova.Text("App"))).Class("logo"),
-		).Class("app-header"),
-		nova.Main(
-			nova.Section(
-				nova.Div(
-					nova.H1(nova.Text("Welcome to "), nova.Span(nova.Text("Nova"))),
-					nova.P().Text("This application demonstrates key features of the Nova framework with a JSON API and server-rendered HTML pages."),
-					nova.H2().Text("Explore Features"),
-					nova.Div(
-						nova.Link("/items", "View All Items").Class("btn btn-secondary"),
-						nova.Link("/create", "Create New Item").Class("btn btn-secondary"),
-						nova.Link("/api/v1/items", "Items JSON API").Class("btn btn-secondary"),
-						nova.Link("/docs", "API Docs").Class("btn btn-secondary"),
-					).Class("cta-buttons"),
-				).Class("container"),
-			).Class("content-section"),
-		).Class("container"),
+	l := tr(rc.Request().Context())
+	return renderPage(rc, page{Title: l.T("home.title")},
+		nova.H1(nova.Text(l.T("home.welcome")), nova.Span(nova.Text("Nova"))),
+		nova.P().Text(l.T("home.intro")),
+		nova.H2().Text(l.T("home.explore")),
+		nova.Div(
+			nova.Link("/items", l.T("home.view_items")).Class("btn btn-secondary"),
+			nova.Link("/create", l.T("home.create_item")).Class("btn btn-secondary"),
+			nova.Link("/api/v1/items", l.T("home.items_api")).Class("btn btn-secondary"),
+			nova.Link("/docs", l.T("home.api_docs")).Class("btn btn-secondary"),
+		).Class("cta-buttons"),
 	)
-
-	return rc.HTML(http.StatusOK, doc)
 }
 
 // handleItemsListPage renders a table view of all items with action buttons.
 // It demonstrates dynamic HTML generation based on application data.
+// The table is searched, filtered, sorted and paginated by query parameters, see itemsQuery.
 func handleItemsListPage(rc *nova.ResponseContext) error {
-	// Get all items with thread safety
+	// Get all items of the current tenant with thread safety
 	mu.Lock()
-	itemsList := make([]Item, 0, len(items))
-	for _, item := range items {
+	store := readStore(tenantFrom(rc.Request().Context()))
+	itemsList := make([]Item, 0, len(store.items))
+	for _, item := range store.items {
 		itemsList = append(itemsList, item)
 	}
+	empty := len(store.items) == 0
+	fields := slices.Clone(store.fields)
 	mu.Unlock()
 
+	l := tr(rc.Request().Context())
+	query := parseItemsQuery(rc.Request().URL.Query())
+	pageItems, total := query.apply(itemsList)
+
 	// Build table rows dynamically
-	rows := make([]nova.HTMLElement, 0, len(itemsList))
-	for _, item := range itemsList {
-		statusBadge := "Inactive"
-		if item.IsActive {
-			statusBadge = "Active"
-		}
-		row := nova.Tr(
+	rows := make([]nova.HTMLElement, 0, len(pageItems))
+	for _, item := range pageItems {
+		cells := []nova.HTMLElement{
 			nova.Td().Text(strconv.Itoa(item.ID)),
-			nova.Td().Text(item.Name),
-			nova.Td().Text(item.CreatedAt.Format("Jan 02, 2006 15:04")),
-			nova.Td().Text(statusBadge),
-			nova.Td(
-				nova.Link(fmt.Sprintf("/api/v1/items/%d", item.ID), "View JSON").
-					Class("btn btn-secondary").
-					Style("font-size: 0.8em; padding: 0.3em 0.6em;"),
-			),
+			nova.Td(nova.Link(fmt.Sprintf("/items/%d", item.ID), item.Name)),
+			nova.Td(priorityBadge(l, item.Priority)),
+			nova.Td(localTime(l, item.CreatedAt)),
+			nova.Td(localTime(l, item.UpdatedAt)),
+			nova.Td(statusBadge(l, item.IsActive)),
+			nova.Td(tagList(item.Tags, func(tag string) string { return query.withTag(tag).url() })),
+		}
+		for _, f := range fields {
+			cells = append(cells, nova.Td(customFieldValue(l, f, item.CustomFields[f.Name])))
+		}
+		cells = append(cells, nova.Td(
+			nova.Link(fmt.Sprintf("/items/%d", item.ID), l.T("items.view")).
+				Class("btn btn-secondary btn-small"),
+			nova.Link(fmt.Sprintf("/api/v1/items/%d", item.ID), l.T("items.view_json")).
+				Class("btn btn-secondary btn-small"),
+		),
 		)
-		rows = append(rows, row)
+		rows = append(rows, nova.Tr(cells...))
 	}
 
 	// Create table or empty state message
 	var tableContent nova.HTMLElement
-	if len(rows) == 0 {
-		tableContent = nova.P().Text("No items found. Create your first item to get started!")
-	} else {
-		tableContent = nova.Table(
-			nova.Thead(
-				nova.Tr(
-					nova.Th().Text("ID"),
-					nova.Th().Text("Name"),
-					nova.Th().Text("Created At"),
-					nova.Th().Text("Status"),
-					nova.Th().Text("Actions"),
-				),
-			),
-			nova.Tbody(rows...),
-		).Class("table")
-	}
-
-	doc := nova.Document(
-		nova.DocumentConfig{
-			Title: "Items List",
-			HeadExtras: []nova.HTMLElement{
-				nova.Favicon("/static/favicon.png"),
-				nova.StyleTag(getCommonStyles()),
-			},
-		},
-		nova.Header(
-			nova.A("/", nova.Text("Nova"), nova.Span(nova.Text("App"))).Class("logo"),
-		).Class("app-header"),
-		nova.Main(
-			nova.Section(
-				nova.Div(
-					nova.H1().Text("Items Management"),
-					tableContent,
-					nova.Div(
-						nova.Link("/", "Back to Home").Class("btn btn-secondary"),
-						nova.Link("/create", "Create New Item").Class("btn btn-primary"),
-					).Class("cta-buttons").Style("margin-top: 1rem; justify-content: center;"),
-				).Class("container"),
-			).Class("content-section"),
-		).Class("container"),
+	switch {
+	case empty:
+		tableContent = nova.P().Text(l.T("items.empty"))
+	case len(rows) == 0:
+		tableContent = nova.P().Text(l.T("items.no_match"))
+	default:
+		headers := append(itemSortHeaders(l, query), nova.Th().Text(l.T("items.column.tags")))
+		for _, f := range fields {
+			headers = append(headers, nova.Th().Text(f.label()))
+		}
+		headers = append(headers, nova.Th().Text(l.T("items.actions")))
+		tableContent = nova.Div(
+			nova.Table(
+				nova.Thead(nova.Tr(headers...)),
+				nova.Tbody(rows...),
+			).Class("table"),
+			itemPagination(l, query, total),
+		)
+	}
+
+	var filters nova.HTMLElement = nova.Text("")
+	if !empty {
+		filters = itemFilterBar(l, query)
+	}
+
+	return renderPage(rc, page{Title: l.T("items.title"), Breadcrumbs: itemsCrumbs(l)},
+		nova.H1().Text(l.T("items.heading")),
+		filters,
+		tableContent,
+		nova.Div(
+			nova.Link("/", l.T("items.back_home")).Class("btn btn-secondary"),
+			nova.Link("/create", l.T("items.create")).Class("btn btn-primary"),
+		).Class("cta-buttons cta-compact"),
 	)
+}
+
+// itemForm describes the variant of the item form being rendered.
+type itemForm struct {
+	Title  string // Page title and heading
+	Action string // URL the form posts to
+	Submit string // Label of the submit button
+	Cancel string // URL the cancel button returns to
+	Page   string // Path of the page showing the form
+	Crumbs []crumb
+}
 
-	return rc.HTML(http.StatusOK, doc)
+// createItemForm is the form for adding a new item.
+func createItemForm(l *locale) itemForm {
+	return itemForm{
+		Title:  l.T("form.create_title"),
+		Action: "/api/v1/items",
+		Submit: l.T("form.create_submit"),
+		Cancel: "/items",
+		Page:   "/create",
+		Crumbs: itemsCrumbs(l, crumb{Label: l.T("crumb.create")}),
+	}
+}
+
+// editItemForm is the form for changing item.
+func editItemForm(l *locale, item Item) itemForm {
+	return itemForm{
+		Title:  l.T("form.edit_title"),
+		Action: fmt.Sprintf("/items/%d/edit", item.ID),
+		Submit: l.T("form.edit_submit"),
+		Cancel: fmt.Sprintf("/items/%d", item.ID),
+		Page:   fmt.Sprintf("/items/%d/edit", item.ID),
+		Crumbs: itemsCrumbs(l,
+			crumb{Label: item.Name, Href: fmt.Sprintf("/items/%d", item.ID)},
+			crumb{Label: l.T("crumb.edit")},
+		),
+	}
 }
 
-// renderCreateItemForm renders the Create Item page,
+// renderItemForm renders the create or edit item page,
 // optionally showing an error message and re-populating fields.
-func renderCreateItemForm(
+func renderItemForm(
 	rc *nova.ResponseContext,
+	form itemForm,
 	input NewItemInput,
 	errorMsg string,
 ) error {
+	l := tr(rc.Request().Context())
+
 	// Build the list of children for the container div
 	children := []nova.HTMLElement{
-		nova.H1().Text("Create New Item"),
+		nova.H1().Text(form.Title),
 	}
 
 	// Only append an error banner if there is an error
 	if errorMsg != "" {
 		children = append(children,
-			nova.Div(nova.Text(errorMsg)).
-				Class("error-message").
-				Style("color:#ff6b6b; font-weight:500; margin-bottom:1rem;"),
+			nova.Div(nova.Text(errorMsg)).Class("error-message"),
 		)
 	}
 
@@ -600,74 +591,239 @@ func renderCreateItemForm(
 	nameInput := nova.TextInput("name").
 		ID("name").
 		Attr("required", "true").
-		Attr("maxlength", "50").
-		Attr("placeholder", "Enter item name")
+		Attr("minlength", inputTag(NewItemInput{}, "Name", "minlength")).
+		Attr("maxlength", inputTag(NewItemInput{}, "Name", "maxlength")).
+		Attr("placeholder", l.T("form.name_placeholder"))
 	if input.Name != "" {
 		nameInput.Attr("value", input.Name)
 	}
 
+	descriptionInput := nova.Textarea(nova.Text(input.Description)).
+		Attr("name", "description").
+		ID("description").
+		Attr("rows", "5").
+		Attr("maxlength", inputTag(NewItemInput{}, "Description", "maxlength")).
+		Attr("aria-describedby", "description-hint")
+
+	tagsInput := nova.TextInput("tags").
+		ID("tags").
+		Attr("placeholder", l.T("form.tags_placeholder")).
+		Attr("aria-describedby", "tags-hint")
+	if len(input.Tags) > 0 {
+		tagsInput.Attr("value", strings.Join(input.Tags, ", "))
+	}
+
+	priority := nova.Select().Attr("name", "priority").ID("priority")
+	for _, p := range itemPriorities {
+		priority.Add(selectOption(p, l.T("priority."+p), p == cmp.Or(input.Priority, defaultPriority)))
+	}
+
 	// Checkbox input, preserved on error
 	checkbox := nova.CheckboxInput("isActive").ID("isActive")
 	if input.IsActive {
 		checkbox.Attr("checked", "true")
 	}
 
+	// One control per custom field of the tenant, see customfields.go
+	fields := customFieldsOf(tenantFrom(rc.Request().Context()))
+	customInputs := make([]nova.HTMLElement, 0, len(fields))
+	for _, f := range fields {
+		customInputs = append(customInputs, customFieldInput(l, f, input.CustomFields[f.Name]))
+	}
+
 	// Append the actual form to children
 	children = append(children,
 		nova.Form(
 			nova.Div(
-				nova.Label().Text("Name:").Attr("for", "name"),
+				nova.Label().Text(l.T("form.name")).Attr("for", "name"),
 				nameInput,
 			).Class("form-group"),
 			nova.Div(
-				nova.Label(checkbox, nova.Text(" Item is active")),
+				nova.Label().Text(l.T("form.description")).Attr("for", "description"),
+				descriptionInput,
+				nova.Small(nova.Text(l.T("form.description_hint"))).ID("description-hint").Class("form-hint"),
+			).Class("form-group"),
+			nova.Div(
+				nova.Label().Text(l.T("form.tags")).Attr("for", "tags"),
+				tagsInput,
+				nova.Small(nova.Text(l.T("form.tags_hint"))).ID("tags-hint").Class("form-hint"),
 			).Class("form-group"),
 			nova.Div(
-				nova.SubmitButton("Create Item").Class("btn btn-primary"),
-				nova.A("/items", nova.Text("Cancel")).Class("btn btn-secondary"),
+				nova.Label().Text(l.T("form.priority")).Attr("for", "priority"),
+				priority,
+			).Class("form-group"),
+			nova.Div(customInputs...),
+			nova.Div(
+				nova.Label(checkbox, nova.Text(" "+l.T("form.active"))),
+			).Class("form-group"),
+			nova.Div(
+				nova.SubmitButton(form.Submit).Class("btn btn-primary"),
+				nova.A(form.Cancel, nova.Text(l.T("form.cancel"))).Class("btn btn-secondary"),
 			).Class("form-actions"),
 		).
 			Attr("method", "POST").
-			Attr("action", "/api/v1/items").
+			Attr("action", form.Action).
 			Attr("enctype", "application/x-www-form-urlencoded"),
 		nova.Br(),
-		nova.Link("/", "Back to Home").
-			Class("btn btn-secondary").
-			Style("margin-top:1rem;"),
+		nova.Link("/", l.T("form.back_home")).Class("btn btn-secondary form-back"),
 	)
 
-	doc := nova.Document(
-		nova.DocumentConfig{
-			Title: "Create Item",
-			HeadExtras: []nova.HTMLElement{
-				nova.Favicon("/static/favicon.png"),
-				nova.StyleTag(getCommonStyles()),
-			},
-		},
-		nova.Header(
-			// Corrected line:
-			nova.A("/", nova.Text("Nova"), nova.Span(nova.Text("App"))).Class("logo"),
-		).Class("app-header"),
-		nova.Main(
-			nova.Section(
-				nova.Div(children...).Class("container"),
-			).Class("content-section"),
-		).Class("container"),
+	return renderPage(rc, page{Title: form.Title, Breadcrumbs: form.Crumbs, Path: form.Page}, children...)
+}
+
+// handleItemDetailPage renders all fields of a single item with edit and delete actions.
+// Unknown or malformed IDs get an HTML 404 page, or a JSON error for API clients.
+func handleItemDetailPage(rc *nova.ResponseContext) error {
+	l := tr(rc.Request().Context())
+	id, err := strconv.Atoi(rc.URLParam("itemId"))
+	if err != nil {
+		return renderNotFound(rc, codeInvalidItemID)
+	}
+
+	mu.Lock()
+	store := readStore(tenantFrom(rc.Request().Context()))
+	item, exists := store.items[id]
+	fields := slices.Clone(store.fields)
+	mu.Unlock()
+
+	if !exists {
+		loggerFrom(rc.Request().Context()).Debug("Item not found", "item_id", id)
+		return renderNotFound(rc, codeItemNotFound, id)
+	}
+
+	var description nova.HTMLElement = nova.P().Text(l.T("detail.no_description")).Class("muted")
+	if item.Description != "" {
+		description = nova.Div(renderMarkdown(item.Description)...).Class("markdown")
+	}
+
+	rows := []nova.HTMLElement{
+		nova.Tr(nova.Th().Text(l.T("items.column.id")), nova.Td().Text(strconv.Itoa(item.ID))),
+		nova.Tr(nova.Th().Text(l.T("items.column.name")), nova.Td().Text(item.Name)),
+		nova.Tr(nova.Th().Text(l.T("items.column.priority")), nova.Td(priorityBadge(l, item.Priority))),
+		nova.Tr(nova.Th().Text(l.T("items.column.tags")), nova.Td(tagList(item.Tags, func(tag string) string {
+			return defaultItemsQuery().withTag(tag).url()
+		}))),
+	}
+	for _, f := range fields {
+		rows = append(rows, nova.Tr(nova.Th().Text(f.label()), nova.Td(customFieldValue(l, f, item.CustomFields[f.Name]))))
+	}
+	rows = append(rows,
+		nova.Tr(nova.Th().Text(l.T("items.column.status")), nova.Td(statusBadge(l, item.IsActive))),
+		nova.Tr(nova.Th().Text(l.T("items.column.created")), nova.Td(localTime(l, item.CreatedAt))),
+		nova.Tr(nova.Th().Text(l.T("items.column.updated")), nova.Td(localTime(l, item.UpdatedAt))),
+	)
+
+	return renderPage(rc, page{Title: l.T("detail.title", item.Name), Breadcrumbs: itemsCrumbs(l, crumb{Label: item.Name})},
+		nova.H1().Text(item.Name),
+		nova.Table(nova.Tbody(rows...)).Class("table detail-table"),
+		nova.H2().Text(l.T("detail.description")),
+		description,
+		nova.Div(
+			nova.Link(fmt.Sprintf("/items/%d/edit", item.ID), l.T("detail.edit")).Class("btn btn-primary"),
+			nova.Link(fmt.Sprintf("/items/%d/delete", item.ID), l.T("detail.delete")).Class("btn btn-danger"),
+			nova.Link("/items", l.T("detail.back")).Class("btn btn-secondary"),
+		).Class("cta-buttons"),
+	)
+}
+
+// handleDeleteItemPage asks for confirmation before deleting an item. The form posts
+// to the JSON API with a _method override, since HTML forms cannot send DELETE.
+func handleDeleteItemPage(rc *nova.ResponseContext) error {
+	l := tr(rc.Request().Context())
+	id, err := strconv.Atoi(rc.URLParam("itemId"))
+	if err != nil {
+		return renderNotFound(rc, codeInvalidItemID)
+	}
+
+	mu.Lock()
+	item, exists := readStore(tenantFrom(rc.Request().Context())).items[id]
+	mu.Unlock()
+
+	if !exists {
+		return renderNotFound(rc, codeItemNotFound, id)
+	}
+
+	crumbs := itemsCrumbs(l,
+		crumb{Label: item.Name, Href: fmt.Sprintf("/items/%d", item.ID)},
+		crumb{Label: l.T("crumb.delete")},
+	)
+	return renderPage(rc, page{Title: l.T("delete.title", item.Name), Breadcrumbs: crumbs},
+		nova.H1().Text(l.T("delete.heading")),
+		nova.P().Text(l.T("delete.confirm", item.Name)),
+		nova.Form(
+			nova.HiddenInput("_method", http.MethodDelete),
+			nova.Div(
+				nova.SubmitButton(l.T("delete.submit")).Class("btn btn-danger"),
+				nova.A(fmt.Sprintf("/items/%d", item.ID), nova.Text(l.T("delete.cancel"))).Class("btn btn-secondary"),
+			).Class("form-actions"),
+		).
+			Attr("method", "POST").
+			Attr("action", fmt.Sprintf("/api/v1/items/%d", item.ID)),
+	)
+}
+
+// renderNotFound responds with a 404 HTML page for browsers and a JSON error for API clients,
+// describing the error with the translated message of code.
+func renderNotFound(rc *nova.ResponseContext, code string, args ...any) error {
+	if rc.WantsJSON() {
+		return jsonError(rc, http.StatusNotFound, code, args...)
+	}
+
+	l := tr(rc.Request().Context())
+	message := l.T("error."+code, args...)
+	return renderPage(rc, page{Title: l.T("notfound.title"), Status: http.StatusNotFound},
+		nova.H1(nova.Span(nova.Text("404")), nova.Text(" "+l.T("notfound.heading"))),
+		nova.P().Text(message),
+		nova.Div(
+			nova.Link("/items", l.T("notfound.view_items")).Class("btn btn-primary"),
+			nova.Link("/", l.T("notfound.back_home")).Class("btn btn-secondary"),
+		).Class("cta-buttons"),
 	)
-	return rc.HTML(http.StatusOK, doc)
 }
 
 // handleCreateItemPage now simply calls our renderer with no error.
 func handleCreateItemPage(rc *nova.ResponseContext) error {
-	return renderCreateItemForm(rc, NewItemInput{}, "")
+	return renderItemForm(rc, createItemForm(tr(rc.Request().Context())), NewItemInput{}, "")
+}
+
+// handleEditItemPage renders the item form pre-filled with the item's current values.
+func handleEditItemPage(rc *nova.ResponseContext) error {
+	l := tr(rc.Request().Context())
+	id, err := strconv.Atoi(rc.URLParam("itemId"))
+	if err != nil {
+		return renderNotFound(rc, codeInvalidItemID)
+	}
+
+	mu.Lock()
+	item, exists := readStore(tenantFrom(rc.Request().Context())).items[id]
+	mu.Unlock()
+
+	if !exists {
+		return renderNotFound(rc, codeItemNotFound, id)
+	}
+
+	return renderItemForm(rc, editItemForm(l, item), NewItemInput{
+		Name:         item.Name,
+		Description:  item.Description,
+		Tags:         item.Tags,
+		Priority:     item.Priority,
+		IsActive:     item.IsActive,
+		CustomFields: item.CustomFields,
+	}, "")
 }
 
-// handleGetItems returns a JSON list of all items - minimal boilerplate.
+// handleGetItems returns a JSON list of all items, or of the items carrying every
+// tag given in the tag query parameters.
 func handleGetItems(rc *nova.ResponseContext) error {
+	tags := normalizeTags(rc.Request().URL.Query()["tag"])
+
 	mu.Lock()
-	itemsList := make([]Item, 0, len(items))
-	for _, item := range items {
-		itemsList = append(itemsList, item)
+	store := readStore(tenantFrom(rc.Request().Context()))
+	itemsList := make([]Item, 0, len(store.items))
+	for _, item := range store.items {
+		if hasTags(item, tags) {
+			itemsList = append(itemsList, item)
+		}
 	}
 	mu.Unlock()
 
@@ -679,15 +835,16 @@ func handleGetItem(rc *nova.ResponseContext) error {
 	idStr := rc.URLParam("itemId")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return rc.JSONError(http.StatusBadRequest, "Invalid item ID format")
+		return jsonError(rc, http.StatusBadRequest, codeInvalidItemID)
 	}
 
 	mu.Lock()
-	item, exists := items[id]
+	item, exists := readStore(tenantFrom(rc.Request().Context())).items[id]
 	mu.Unlock()
 
 	if !exists {
-		return rc.JSONError(http.StatusNotFound, fmt.Sprintf("Item %d not found", id))
+		loggerFrom(rc.Request().Context()).Debug("Item not found", "item_id", id)
+		return jsonError(rc, http.StatusNotFound, codeItemNotFound, id)
 	}
 
 	return rc.JSON(http.StatusOK, item)
@@ -696,52 +853,147 @@ func handleGetItem(rc *nova.ResponseContext) error {
 // handleCreateItem binds & validates, then either returns JSON or re-renders the form.
 func handleCreateItem(rc *nova.ResponseContext) error {
 	var input NewItemInput
-	if err := rc.BindValidated(&input); err != nil {
-		// JSON clients get a JSON error
+	if err := bindItemInput(rc, &input); err != nil {
+		loggerFrom(rc.Request().Context()).Info("Item rejected", "error", err)
+		// JSON clients get a JSON error with the rejected fields
 		if rc.WantsJSON() {
-			return rc.JSONError(http.StatusBadRequest, "Invalid input: "+err.Error())
+			return rc.JSON(err.Status(), err.Response(rc.Request().Context()))
 		}
 		// HTML form clients see the form again with errors & previous data
-		return renderCreateItemForm(rc, input, err.Error())
+		l := tr(rc.Request().Context())
+		return renderItemForm(rc, createItemForm(l), input, err.Message(l))
 	}
 
 	mu.Lock()
-	nextItemID++
-	id := nextItemID
+	store := storeFor(tenantFrom(rc.Request().Context()))
+	store.nextItemID++
+	id := store.nextItemID
+	now := time.Now().UTC()
 	item := Item{
-		ID:        id,
-		Name:      input.Name,
-		CreatedAt: time.Now().UTC(),
-		IsActive:  input.IsActive,
+		ID:           id,
+		Name:         input.Name,
+		Description:  input.Description,
+		Tags:         input.Tags,
+		Priority:     cmp.Or(input.Priority, defaultPriority),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		IsActive:     input.IsActive,
+		CustomFields: input.CustomFields,
 	}
-	items[id] = item
+	store.items[id] = item
 	mu.Unlock()
+	notifyItemsChanged(tenantFrom(rc.Request().Context()))
+
+	loggerFrom(rc.Request().Context()).Info("Item created", "item_id", id, "name", item.Name)
 
 	if rc.WantsJSON() {
 		return rc.JSON(http.StatusCreated, item)
 	}
+	setFlash(rc, flashSuccess, tr(rc.Request().Context()).T("flash.created", item.Name))
 	return rc.Redirect(http.StatusFound, "/items")
 }
 
+// handleUpdateItem binds & validates the new values of an item, then either returns JSON
+// or redirects browsers to the detail page, re-rendering the edit form on errors.
+func handleUpdateItem(rc *nova.ResponseContext) error {
+	l := tr(rc.Request().Context())
+	id, err := strconv.Atoi(rc.URLParam("itemId"))
+	if err != nil {
+		if rc.WantsJSON() {
+			return jsonError(rc, http.StatusBadRequest, codeInvalidItemID)
+		}
+		return renderNotFound(rc, codeInvalidItemID)
+	}
+
+	mu.Lock()
+	current, exists := readStore(tenantFrom(rc.Request().Context())).items[id]
+	mu.Unlock()
+	if !exists {
+		return renderNotFound(rc, codeItemNotFound, id)
+	}
+
+	var input NewItemInput
+	if err := bindItemInput(rc, &input); err != nil {
+		loggerFrom(rc.Request().Context()).Info("Item update rejected", "item_id", id, "error", err)
+		if rc.WantsJSON() {
+			return rc.JSON(err.Status(), err.Response(rc.Request().Context()))
+		}
+		return renderItemForm(rc, editItemForm(l, current), input, err.Message(l))
+	}
+
+	mu.Lock()
+	store := readStore(tenantFrom(rc.Request().Context()))
+	current, exists = store.items[id]
+	item := current
+	item.Name = input.Name
+	item.Description = input.Description
+	item.Tags = input.Tags
+	item.Priority = cmp.Or(input.Priority, defaultPriority)
+	item.IsActive = input.IsActive
+	item.CustomFields = input.CustomFields
+	unchanged := reflect.DeepEqual(item, current)
+	if exists && !unchanged {
+		item.UpdatedAt = time.Now().UTC()
+		store.items[id] = item
+	}
+	mu.Unlock()
+
+	// The item may have been deleted while the input was validated
+	if !exists {
+		return renderNotFound(rc, codeItemNotFound, id)
+	}
+	if !unchanged {
+		notifyItemsChanged(tenantFrom(rc.Request().Context()))
+	}
+
+	loggerFrom(rc.Request().Context()).Info("Item updated", "item_id", id, "name", item.Name)
+	if rc.WantsJSON() {
+		return rc.JSON(http.StatusOK, item)
+	}
+	if unchanged {
+		setFlash(rc, flashInfo, l.T("flash.unchanged"))
+	} else {
+		setFlash(rc, flashSuccess, l.T("flash.updated", item.Name))
+	}
+	return rc.Redirect(http.StatusFound, fmt.Sprintf("/items/%d", id))
+}
+
 // handleDeleteItem removes an item with automatic parameter extraction and error handling.
+// Form submissions from the confirmation page are redirected back to the items list.
 func handleDeleteItem(rc *nova.ResponseContext) error {
+	fromForm := isFormSubmission(rc.Request())
 	idStr := rc.URLParam("itemId")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		return rc.JSONError(http.StatusBadRequest, "Invalid item ID format")
+		if fromForm {
+			return renderNotFound(rc, codeInvalidItemID)
+		}
+		return jsonError(rc, http.StatusBadRequest, codeInvalidItemID)
 	}
 
 	mu.Lock()
-	_, exists := items[id]
+	store := readStore(tenantFrom(rc.Request().Context()))
+	item, exists := store.items[id]
 	if exists {
-		delete(items, id)
+		delete(store.items, id)
 	}
 	mu.Unlock()
 
 	if !exists {
-		return rc.JSONError(http.StatusNotFound, fmt.Sprintf("Item %d not found", id))
+		loggerFrom(rc.Request().Context()).Debug("Item to delete not found", "item_id", id)
+		if fromForm {
+			setFlash(rc, flashError, tr(rc.Request().Context()).T("flash.gone", id))
+			return rc.Redirect(http.StatusSeeOther, "/items")
+		}
+		return jsonError(rc, http.StatusNotFound, codeItemNotFound, id)
 	}
 
+	notifyItemsChanged(tenantFrom(rc.Request().Context()))
+	loggerFrom(rc.Request().Context()).Info("Item deleted", "item_id", id)
+	if fromForm {
+		setFlash(rc, flashSuccess, tr(rc.Request().Context()).T("flash.deleted", item.Name))
+		return rc.Redirect(http.StatusSeeOther, "/items")
+	}
 	return rc.JSON(http.StatusOK, map[string]string{
 		"message": "Item deleted successfully",
 		"id":      strconv.Itoa(id),
@@ -755,6 +1007,11 @@ func main() {
 		Version:     "1.0.0",
 		Description: "Nova framework demonstration with HTML and JSON APIs, auto-reload, and minimal boilerplate",
 		GlobalFlags: []nova.Flag{
+			&nova.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Path to a JSON or TOML configuration file (also NOVA_CONFIG)",
+			},
 			&nova.StringFlag{
 				Name:    "host",
 				Aliases: []string{"H"},
@@ -775,7 +1032,7 @@ func main() {
 			&nova.BoolFlag{
 				Name:    "watch",
 				Aliases: []string{"w"},
-				Usage:   "Enable live file watching and auto-reload during development",
+				Usage:   "Rebuild and restart on source changes and reload open pages (development)",
 			},
 			&nova.StringFlag{
 				Name:    "extensions",
@@ -783,6 +1040,38 @@ func main() {
 				Default: ".go",
 				Usage:   "File extensions to watch for changes (comma-separated)",
 			},
+			&nova.StringFlag{
+				Name:    "tenant_header",
+				Default: "X-Tenant-ID",
+				Usage:   "Request header carrying the tenant ID (empty disables header resolution)",
+			},
+			&nova.StringFlag{
+				Name:  "tenant_domain",
+				Usage: "Base domain under which tenants are served as subdomains (e.g. example.com)",
+			},
+			&nova.StringFlag{
+				Name:  "tenant_secret",
+				Usage: "HMAC secret for verifying HS256 bearer tokens carrying a tenant claim",
+			},
+			&nova.StringFlag{
+				Name:  "tenants",
+				Usage: "Comma-separated list of accepted tenant IDs (empty accepts any)",
+			},
+			&nova.IntFlag{
+				Name:    "rate_limit",
+				Default: 120,
+				Usage:   "Requests per minute allowed per client (0 disables rate limiting)",
+			},
+			&nova.StringFlag{
+				Name:  "trusted_proxies",
+				Usage: "Comma-separated CIDRs of proxies whose X-Forwarded-For header is trusted",
+			},
+			&nova.StringFlag{
+				Name:    "shutdown_timeout",
+				Aliases: []string{"shutdown-timeout"},
+				Default: "30s",
+				Usage:   "Maximum time to drain in-flight requests and flush pending work on shutdown",
+			},
 			&nova.StringFlag{
 				Name:    "log_format",
 				Aliases: []string{"f"},
@@ -797,53 +1086,56 @@ func main() {
 			},
 		},
 		Action: func(ctx *nova.Context) error {
+			// Merge flags, environment and config file into one validated configuration
+			load := func() (*loadedConfig, error) { return loadConfig(ctx, os.Args[1:]) }
+			cfg, err := load()
+			if err != nil {
+				return err
+			}
+
+			// All logging goes through slog, formatted as the configuration asks
+			configureSlog(cfg.LogFormat, cfg.LogLevel)
+
+			// In watch mode this process only supervises: it rebuilds on changes and runs each build
+			if cfg.Watch {
+				return runDevServer(cfg.appConfig)
+			}
+
+			// Reload the configuration on SIGHUP or when the config file changes
+			live := newLiveConfig(cfg, load)
+			watchCtx, stopWatching := context.WithCancel(context.Background())
+			go live.watch(watchCtx, 2*time.Second)
+			onShutdown("config watcher", func(context.Context) error {
+				stopWatching()
+				return nil
+			})
+
 			// Initialize router
 			router := nova.NewRouter()
 
-			// Apply middleware stack
-			router.Use(
-				nova.RecoveryMiddleware(&nova.RecoveryConfig{}),
-				nova.RequestIDMiddleware(&nova.RequestIDConfig{
-					HeaderName: "X-Request-ID",
-				}),
-				nova.LoggingMiddleware(&nova.LoggingConfig{
-					Logger:       log.Default(),
-					LogRequestID: true,
-				}),
-				nova.SecurityHeadersMiddleware(nova.SecurityHeadersConfig{
-					ContentTypeOptions:    "nosniff",
-					FrameOptions:          "DENY",
-					ReferrerPolicy:        "strict-origin-when-cross-origin",
-					HSTSMaxAgeSeconds:     31536000, // 1 year
-					HSTSIncludeSubdomains: func(b bool) *bool { return &b }(true),
-					HSTSPreload:           false,
-				}),
-				nova.CORSMiddleware(nova.CORSConfig{
-					AllowedOrigins:   []string{"*"},
-					AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-					AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Request-ID"},
-					AllowCredentials: false,
-					MaxAgeSeconds:    86400, // 24 hours
-				}),
-				nova.TrailingSlashRedirectMiddleware(nova.TrailingSlashRedirectConfig{
-					AddSlash:     false,
-					RedirectCode: http.StatusMovedPermanently,
-				}),
-			)
+			// Apply the middleware stack selected by the configuration
+			router.Use(live.middleware)
 
 			// Setup all routes
 			setupRoutes(router)
 
-			// Start the Nova server
-			return nova.Serve(ctx, router)
+			// Start the server with graceful shutdown
+			return serve(cfg.appConfig, methodOverride(router))
 		},
 	})
 
 	if err != nil {
-		log.Fatalf("Failed to initialize CLI: %v", err)
+		slog.Error("Failed to initialize CLI", "error", err)
+		os.Exit(exitFailure)
 	}
 
 	if err := cli.Run(os.Args); err != nil {
-		log.Fatalf("Application error: %v", err)
+		code := exitFailure
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		slog.Error("Application error", "error", err)
+		os.Exit(code)
 	}
 }
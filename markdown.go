@@ -0,0 +1,206 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// markdownEscapable are the characters a backslash makes literal.
+const markdownEscapable = "\\`*_[]()#>-!"
+
+var (
+	markdownHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	markdownBullet      = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	markdownNumbered    = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	markdownSafeLinkURL = regexp.MustCompile(`^(https?://|mailto:|/$|/[^/\\]|#)`)
+)
+
+// renderMarkdown renders the subset of Markdown item descriptions support: paragraphs,
+// headings, bulleted and numbered lists, block quotes and fenced code blocks, with
+// code spans, emphasis and links inside them. Raw HTML is shown as text, and links are
+// only followed to http, https and mailto URLs or paths of the site. Browsers read
+// "/\host" like "//host", so neither counts as a path of the site.
+func renderMarkdown(src string) []nova.HTMLElement {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var blocks []nova.HTMLElement
+	var paragraph, quote []string
+	var list *nova.Element
+	listNumbered := false
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, nova.P(markdownInline(strings.Join(paragraph, " "))...))
+			paragraph = nil
+		}
+		if len(quote) > 0 {
+			blocks = append(blocks, nova.Blockquote(nova.P(markdownInline(strings.Join(quote, " "))...)))
+			quote = nil
+		}
+		if list != nil {
+			blocks = append(blocks, list)
+			list = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if strings.HasPrefix(line, "```") {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			blocks = append(blocks, nova.Pre(nova.Code(nova.Text(strings.Join(code, "\n")))))
+			continue
+		}
+
+		if line == "" {
+			flush()
+			continue
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			blocks = append(blocks, markdownHeadingElement(len(m[1]), markdownInline(m[2])))
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, ">"); ok {
+			if len(quote) == 0 {
+				flush()
+			}
+			quote = append(quote, strings.TrimSpace(rest))
+			continue
+		}
+		bullet := markdownBullet.FindStringSubmatch(line)
+		numbered := markdownNumbered.FindStringSubmatch(line)
+		if bullet != nil || numbered != nil {
+			if list == nil || listNumbered != (numbered != nil) {
+				flush()
+				listNumbered = numbered != nil
+				if listNumbered {
+					list = nova.Ol()
+				} else {
+					list = nova.Ul()
+				}
+			}
+			item := bullet
+			if numbered != nil {
+				item = numbered
+			}
+			list.Add(nova.Li(markdownInline(item[1])...))
+			continue
+		}
+		if list != nil || len(quote) > 0 {
+			flush()
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+	return blocks
+}
+
+// markdownHeadingElement returns a heading of the given Markdown level, moved down two
+// levels so descriptions cannot compete with the headings of the page.
+func markdownHeadingElement(level int, content []nova.HTMLElement) nova.HTMLElement {
+	switch level {
+	case 1:
+		return nova.H3(content...)
+	case 2:
+		return nova.H4(content...)
+	case 3:
+		return nova.H5(content...)
+	default:
+		return nova.H6(content...)
+	}
+}
+
+// markdownInline renders code spans, strong and emphasized text and links in s.
+// Markers without a closing counterpart are kept as text.
+func markdownInline(s string) []nova.HTMLElement {
+	var out []nova.HTMLElement
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			out = append(out, nova.Text(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(markdownEscapable, s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				flush()
+				out = append(out, nova.Code(nova.Text(s[i+1:i+1+end])))
+				i += end + 2
+				continue
+			}
+		case (c == '*' || c == '_') && strings.HasPrefix(s[i+1:], string(c)) && markdownOpens(s, i):
+			marker := s[i : i+2]
+			if end := strings.Index(s[i+2:], marker); end > 0 {
+				flush()
+				out = append(out, nova.Strong(markdownInline(s[i+2:i+2+end])...))
+				i += end + 4
+				continue
+			}
+		case (c == '*' || c == '_') && markdownOpens(s, i):
+			if end := strings.IndexByte(s[i+1:], c); end > 0 {
+				flush()
+				out = append(out, nova.Em(markdownInline(s[i+1:i+1+end])...))
+				i += end + 2
+				continue
+			}
+		case c == '[':
+			if label, href, n, ok := markdownLink(s[i:]); ok {
+				flush()
+				if markdownSafeLinkURL.MatchString(href) {
+					out = append(out, nova.Link(href, label).Attr("rel", "nofollow noopener"))
+				} else {
+					out = append(out, nova.Text(label))
+				}
+				i += n
+				continue
+			}
+		}
+		text.WriteByte(c)
+		i++
+	}
+	flush()
+	return out
+}
+
+// markdownOpens reports whether the * or _ at s[i] may open emphasis. Underscores
+// inside words, as in snake_case, are literal.
+func markdownOpens(s string, i int) bool {
+	if s[i] != '_' || i == 0 {
+		return true
+	}
+	prev := s[i-1]
+	return !(prev >= 'a' && prev <= 'z' || prev >= 'A' && prev <= 'Z' || prev >= '0' && prev <= '9')
+}
+
+// markdownLink parses a "[label](href)" link at the start of s and returns its parts
+// and length.
+func markdownLink(s string) (label, href string, n int, ok bool) {
+	closeLabel := strings.Index(s, "](")
+	if closeLabel < 0 {
+		return "", "", 0, false
+	}
+	closeHref := strings.IndexByte(s[closeLabel+2:], ')')
+	if closeHref < 0 {
+		return "", "", 0, false
+	}
+	label = s[1:closeLabel]
+	href = strings.TrimSpace(s[closeLabel+2 : closeLabel+2+closeHref])
+	if label == "" || href == "" || strings.ContainsAny(href, " \t") {
+		return "", "", 0, false
+	}
+	return label, href, closeLabel + 3 + closeHref, true
+}
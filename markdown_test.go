@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "paragraphs", src: "one\ntwo\n\nthree", want: "<p>one two</p><p>three</p>"},
+		{name: "heading moved down", src: "# Title", want: "<h3>Title</h3>"},
+		{name: "lists", src: "- a\n- b\n1. c", want: "<ul><li>a</li><li>b</li></ul><ol><li>c</li></ol>"},
+		{name: "quote", src: "> quoted\n> text", want: "<blockquote><p>quoted text</p></blockquote>"},
+		{name: "code block", src: "```\n<b>x</b>\n```", want: "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>"},
+		{name: "inline", src: "**bold** *em* `code` snake_case_name", want: "<p><strong>bold</strong> <em>em</em> <code>code</code> snake_case_name</p>"},
+		{name: "escaped marker", src: `\*not em\*`, want: "<p>*not em*</p>"},
+
+		{name: "raw html", src: `<script>alert(1)</script>`, want: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{name: "raw html attribute", src: `<img src=x onerror="alert(1)">`, want: "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>"},
+		{name: "html in link label", src: `[<b>x</b>](https://example.com)`, want: `<p><a href="https://example.com">&lt;b&gt;x&lt;/b&gt;</a></p>`},
+
+		{name: "https link", src: "[site](https://example.com/a?b=c&d)", want: `<p><a href="https://example.com/a?b=c&amp;d">site</a></p>`},
+		{name: "mailto link", src: "[mail](mailto:a@example.com)", want: `<p><a href="mailto:a@example.com">mail</a></p>`},
+		{name: "site path", src: "[items](/items)", want: `<p><a href="/items">items</a></p>`},
+		{name: "fragment", src: "[top](#top)", want: `<p><a href="#top">top</a></p>`},
+		{name: "javascript link", src: "[click](javascript:alert(1))", want: "<p>click)</p>"},
+		{name: "javascript link uppercase", src: "[click](JAVASCRIPT:alert`1`)", want: "<p>click</p>"},
+		{name: "javascript link with entity", src: "[click](javascript&#58;alert`1`)", want: "<p>click</p>"},
+		{name: "data link", src: "[click](data:text/html,x)", want: "<p>click</p>"},
+		{name: "vbscript link", src: "[click](vbscript:msgbox)", want: "<p>click</p>"},
+		{name: "protocol-relative link", src: "[click](//evil.example)", want: "<p>click</p>"},
+		{name: "backslash protocol-relative link", src: `[click](/\evil.example)`, want: "<p>click</p>"},
+		{name: "quote in href", src: `[click](https://example.com/"onmouseover="alert)`, want: `<p><a href="https://example.com/&#34;onmouseover=&#34;alert">click</a></p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strings.Builder
+			for _, block := range renderMarkdown(tt.src) {
+				got.WriteString(block.Render())
+			}
+			// Attribute order is not stable, so rel is checked on its own
+			html := got.String()
+			if links, rels := strings.Count(html, "<a "), strings.Count(html, ` rel="nofollow noopener"`); links != rels {
+				t.Errorf("%d links but %d with rel=nofollow noopener in %s", links, rels, html)
+			}
+			html = strings.ReplaceAll(html, ` rel="nofollow noopener"`, "")
+			if html != tt.want {
+				t.Errorf("renderMarkdown(%q)\n got %s\nwant %s", tt.src, html, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency histogram.
+// They match the default buckets of the official Prometheus client libraries.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestLabels identifies one time series of the HTTP request metrics.
+type requestLabels struct {
+	route  string
+	method string
+	status string
+}
+
+// standardMethods are the request methods kept in the method label. Any other method
+// is counted as "other", so clients cannot create new series by making methods up.
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// methodLabel returns the method label of a request method.
+func methodLabel(method string) string {
+	if slices.Contains(standardMethods, method) {
+		return method
+	}
+	return "other"
+}
+
+// histogram is a cumulative Prometheus histogram with fixed buckets.
+type histogram struct {
+	counts []uint64 // one count per bucket in latencyBuckets
+	count  uint64
+	sum    float64
+}
+
+// metricSample is a single gauge value with its label pairs.
+type metricSample struct {
+	labels [][2]string
+	value  float64
+}
+
+// gaugeFunc is a gauge whose samples are computed at scrape time.
+type gaugeFunc struct {
+	name    string
+	help    string
+	collect func() []metricSample
+}
+
+// metricsRegistry collects HTTP request metrics and domain gauges and renders them
+// in the Prometheus text exposition format without external dependencies.
+type metricsRegistry struct {
+	mu        sync.Mutex
+	requests  map[requestLabels]uint64
+	latencies map[requestLabels]*histogram
+	gauges    []gaugeFunc
+	inFlight  atomic.Int64
+}
+
+// appMetrics is the registry exposed on /metrics.
+var appMetrics = newMetricsRegistry()
+
+// newMetricsRegistry creates an empty registry.
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests:  make(map[requestLabels]uint64),
+		latencies: make(map[requestLabels]*histogram),
+	}
+}
+
+// registerGauge adds a gauge computed by collect every time the metrics are scraped.
+func (m *metricsRegistry) registerGauge(name, help string, collect func() []metricSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges = append(m.gauges, gaugeFunc{name: name, help: help, collect: collect})
+}
+
+// observe records a finished request.
+func (m *metricsRegistry) observe(labels requestLabels, duration time.Duration) {
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[labels]++
+	h, ok := m.latencies[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latencies[labels] = h
+	}
+	for i, upper := range latencyBuckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code and body size.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WriteHeader captures the status code before delegating.
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write defaults the status to 200 like net/http does and counts the bytes written.
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer supports it.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// metricsMiddleware counts requests and measures their latency, labelled by
+// route pattern, method and status code. Nova runs middlewares for matched routes
+// only, so requests for unknown paths are not counted.
+func metricsMiddleware(m *metricsRegistry) nova.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			m.inFlight.Add(1)
+			defer m.inFlight.Add(-1)
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			m.observe(requestLabels{
+				route:  knownRoutes.lookup(r.URL.Path),
+				method: methodLabel(r.Method),
+				status: strconv.Itoa(rec.status),
+			}, time.Since(start))
+		})
+	}
+}
+
+// handleMetrics serves all metrics in the Prometheus text exposition format.
+func handleMetrics(rc *nova.ResponseContext) error {
+	w := rc.Writer()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	appMetrics.write(bw)
+	return bw.Flush()
+}
+
+// write renders the registry. Series are sorted so the output is stable between scrapes.
+func (m *metricsRegistry) write(w *bufio.Writer) {
+	m.mu.Lock()
+	keys := make([]requestLabels, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests handled.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "http_requests_total%s %d\n", k.labelString(), m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds Latency of HTTP requests in seconds.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for _, k := range keys {
+		h := m.latencies[k]
+		base := k.labelString()
+		base = base[:len(base)-1] // reopen the label set to append le
+		for i, upper := range latencyBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket%s,le=\"%s\"} %d\n",
+				base, formatFloat(upper), h.counts[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket%s,le=\"+Inf\"} %d\n", base, h.count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum%s %s\n", k.labelString(), formatFloat(h.sum))
+		fmt.Fprintf(w, "http_request_duration_seconds_count%s %d\n", k.labelString(), h.count)
+	}
+	gauges := append([]gaugeFunc(nil), m.gauges...)
+	m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP http_requests_in_flight Number of HTTP requests currently being served.")
+	fmt.Fprintln(w, "# TYPE http_requests_in_flight gauge")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", m.inFlight.Load())
+
+	// Gauges are collected outside m.mu since they may take other locks, such as the store's.
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+		for _, s := range g.collect() {
+			fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(s.labels), formatFloat(s.value))
+		}
+	}
+}
+
+// labelString renders the labels of a request series, e.g. {method="GET",route="/",status="200"}.
+func (k requestLabels) labelString() string {
+	return formatLabels([][2]string{{"method", k.method}, {"route", k.route}, {"status", k.status}})
+}
+
+// formatLabels renders label pairs in exposition format, escaping values as required.
+func formatLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf(`%s="%s"`, l[0], escaper.Replace(l[1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// formatFloat renders a sample value the way Prometheus clients do.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// registerItemGauges exposes the number of items and active items per tenant.
+func registerItemGauges(m *metricsRegistry) {
+	collect := func(activeOnly bool) []metricSample {
+		mu.Lock()
+		defer mu.Unlock()
+
+		tenants := make([]string, 0, len(stores))
+		for tenant := range stores {
+			tenants = append(tenants, tenant)
+		}
+		sort.Strings(tenants)
+
+		samples := make([]metricSample, 0, len(tenants))
+		for _, tenant := range tenants {
+			count := 0
+			for _, item := range stores[tenant].items {
+				if !activeOnly || item.IsActive {
+					count++
+				}
+			}
+			samples = append(samples, metricSample{
+				labels: [][2]string{{"tenant", tenant}},
+				value:  float64(count),
+			})
+		}
+		return samples
+	}
+
+	m.registerGauge("nova_items", "Number of items in the store.",
+		func() []metricSample { return collect(false) })
+	m.registerGauge("nova_active_items", "Number of active items in the store.",
+		func() []metricSample { return collect(true) })
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// routeGroup is a set of routes that shares its own CORS and security header policy.
+type routeGroup struct {
+	name string
+	// contains reports whether a route pattern belongs to the group.
+	contains    func(pattern string) bool
+	middlewares []nova.Middleware
+}
+
+// isAPIRoute reports whether pattern belongs to the JSON API.
+func isAPIRoute(pattern string) bool {
+	return strings.HasPrefix(pattern, "/api/")
+}
+
+// isPageRoute reports whether pattern belongs to the HTML pages, see pageRoutes.
+func isPageRoute(pattern string) bool {
+	return pageRoutes.has(pattern)
+}
+
+// isTenantRoute reports whether pattern serves the data of a tenant: the JSON API, the
+// HTML pages and the OpenAPI specification, whose Item schema lists the custom fields.
+func isTenantRoute(pattern string) bool {
+	return isAPIRoute(pattern) || isPageRoute(pattern) || pattern == "/openapi.json"
+}
+
+// middlewareStack builds the global middleware chain from the configuration.
+// Optional middlewares that are disabled in the configuration are left out entirely.
+// The rate limiter is passed in because it holds the client buckets, which must
+// survive a configuration reload that does not change the rate limit settings.
+func middlewareStack(cfg *appConfig, limiter nova.Middleware) []nova.Middleware {
+	stack := []nova.Middleware{metricsMiddleware(appMetrics)}
+
+	if cfg.RequestID.Enabled {
+		stack = append(stack, nova.RequestIDMiddleware(&nova.RequestIDConfig{
+			HeaderName: cfg.RequestID.Header,
+		}))
+	}
+
+	// The request logger is always installed since handlers log through it;
+	// request_log.enabled only controls the per-request access log line.
+	// Recovery runs inside it so that panics are logged with the request's attributes.
+	stack = append(stack, requestLogMiddleware(cfg.RequestLog))
+
+	// Compression wraps recovery, so the error page of a recovered panic is written through it
+	if cfg.Compression.Enabled {
+		stack = append(stack, compressionMiddleware(cfg.Compression))
+	}
+
+	stack = append(stack,
+		recoveryMiddleware,
+		// The language is known before the limiter and tenant resolution, so their errors are translated too
+		localeMiddleware(cfg.Locale),
+		limiter,
+		tenantMiddleware(tenantConfig{
+			HeaderName:     cfg.Tenant.Header,
+			BaseDomain:     cfg.Tenant.Domain,
+			TokenSecret:    cfg.Tenant.Secret,
+			AllowedTenants: cfg.Tenant.Allowed,
+			Scoped:         isTenantRoute,
+		}),
+		flashMiddleware(cfg.Session.Secret),
+		themeMiddleware(cfg.Theme),
+		adminMiddleware(cfg.Admin),
+		groupPolicyMiddleware([]routeGroup{
+			{name: "api", contains: isAPIRoute, middlewares: policyMiddlewares(cfg.API.CORS, cfg.API.Security)},
+			{name: "pages", contains: isPageRoute, middlewares: policyMiddlewares(cfg.Pages.CORS, cfg.Pages.Security)},
+		}, policyMiddlewares(cfg.CORS, cfg.Security)),
+	)
+
+	if cfg.CSP.Enabled {
+		stack = append(stack, cspMiddleware(cfg.CSP))
+	}
+
+	if cfg.TrailingSlash.Enabled {
+		stack = append(stack, nova.TrailingSlashRedirectMiddleware(nova.TrailingSlashRedirectConfig{
+			AddSlash:     cfg.TrailingSlash.AddSlash,
+			RedirectCode: cfg.TrailingSlash.RedirectCode,
+		}))
+	}
+
+	// The cache runs last, so it stores what the handler rendered and nothing else;
+	// compression runs in front of it and compresses cached responses like any other.
+	if cfg.Cache.Enabled {
+		stack = append(stack, cacheMiddleware(cfg.Cache, cfg.Tenant))
+	}
+	return stack
+}
+
+// appRateLimiter creates the rate limiting middleware with the application's route quotas.
+func appRateLimiter(settings rateLimitSettings) nova.Middleware {
+	return rateLimitMiddleware(rateLimitConfig{
+		Default: rateLimit{Requests: settings.RequestsPerMinute, Duration: time.Minute},
+		Routes: []routeRateLimit{
+			{Method: http.MethodPost, Pattern: "/api/v1/items", Limit: rateLimit{Requests: 10, Duration: time.Minute, Burst: 5}},
+			// Probes must never be throttled, or an orchestrator could take a healthy instance out of rotation
+			{Pattern: "/healthz"},
+			{Pattern: "/readyz"},
+			{Pattern: "/livez"},
+		},
+		APIKeys:           settings.APIKeys,
+		TrustedProxyCIDRs: settings.TrustedProxies,
+	})
+}
+
+// policyMiddlewares returns the security header and CORS middlewares for one policy,
+// leaving out the ones that are disabled.
+func policyMiddlewares(cors corsSettings, security securitySettings) []nova.Middleware {
+	var mws []nova.Middleware
+	if security.Enabled {
+		mws = append(mws, nova.SecurityHeadersMiddleware(nova.SecurityHeadersConfig{
+			ContentTypeOptions:    security.ContentTypeOptions,
+			FrameOptions:          security.FrameOptions,
+			ReferrerPolicy:        security.ReferrerPolicy,
+			HSTSMaxAgeSeconds:     security.HSTSMaxAgeSeconds,
+			HSTSIncludeSubdomains: &security.HSTSIncludeSubdomains,
+			HSTSPreload:           security.HSTSPreload,
+		}))
+	}
+	if cors.Enabled {
+		mws = append(mws, nova.CORSMiddleware(nova.CORSConfig{
+			AllowedOrigins:   cors.AllowedOrigins,
+			AllowedMethods:   cors.AllowedMethods,
+			AllowedHeaders:   cors.AllowedHeaders,
+			ExposedHeaders:   cors.ExposedHeaders,
+			AllowCredentials: cors.AllowCredentials,
+			MaxAgeSeconds:    cors.MaxAgeSeconds,
+		}))
+	}
+	return mws
+}
+
+// groupPolicyMiddleware runs the middlewares of the first group containing the matched
+// route, or fallback for routes outside every group. Nova runs the middlewares of a
+// router group inside the global stack, where they could only add to the global CORS
+// and security headers; selecting the chain here keeps every route under exactly one policy.
+func groupPolicyMiddleware(groups []routeGroup, fallback []nova.Middleware) nova.Middleware {
+	return func(next http.Handler) http.Handler {
+		handlers := make([]http.Handler, len(groups))
+		for i, g := range groups {
+			handlers[i] = chainMiddlewares(next, g.middlewares)
+		}
+		fallbackHandler := chainMiddlewares(next, fallback)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := knownRoutes.lookup(r.URL.Path)
+			for i, g := range groups {
+				if g.contains(route) {
+					handlers[i].ServeHTTP(w, r)
+					return
+				}
+			}
+			fallbackHandler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// overrideMethods are the methods an HTML form may ask for in its _method field.
+var overrideMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// methodOverride lets HTML forms, which can only send GET and POST, issue PUT, PATCH
+// and DELETE through a _method field. Only same-origin POST form submissions are
+// overridden, so neither JSON clients, links nor forms on other sites can change the
+// method. It wraps the router instead of being part of the middleware stack because
+// nova selects the route by method before running any middleware.
+func methodOverride(router http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && isFormSubmission(r) && isSameOrigin(r) {
+			limitBody(w, r)
+			if err := parseForm(r); err != nil {
+				// The handler parses the form again and reports the error, e.g. a body over maxBodyBytes
+				r.Form, r.PostForm, r.MultipartForm = nil, nil, nil
+			} else if method := strings.ToUpper(r.PostForm.Get("_method")); slices.Contains(overrideMethods, method) {
+				r.Method = method
+			}
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+// isFormSubmission reports whether r carries an HTML form, as opposed to a JSON API call.
+func isFormSubmission(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// isSameOrigin reports whether the browser sent r from a page of this site, going by
+// Sec-Fetch-Site or, in browsers without it, Origin. Requests with neither header are
+// not treated as same-origin.
+func isSameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		// "none" is a navigation the user started, e.g. from a bookmark
+		return site == "same-origin" || site == "none"
+	}
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil || origin.Host == "" {
+		return false
+	}
+	return strings.EqualFold(origin.Host, r.Host)
+}
+
+// parseForm parses the urlencoded or multipart form body of r. ParseMultipartForm alone
+// drops the error of a urlencoded body, such as one over maxBodyBytes.
+func parseForm(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	return nil
+}
+
+// chainMiddlewares wraps h so that mws run in order, the first one outermost.
+func chainMiddlewares(h http.Handler, mws []nova.Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
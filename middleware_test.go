@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+func TestMethodOverride(t *testing.T) {
+	handler := methodOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method))
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		query       string
+		header      string
+		fetchSite   string
+		origin      string
+		want        string
+	}{
+		{name: "form delete", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", fetchSite: "same-origin", want: http.MethodDelete},
+		{name: "form put lowercase", method: http.MethodPost, contentType: "application/x-www-form-urlencoded; charset=utf-8", body: "_method=put", fetchSite: "same-origin", want: http.MethodPut},
+		{name: "form patch", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=PATCH", fetchSite: "same-origin", want: http.MethodPatch},
+		{name: "form get not allowed", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=GET", fetchSite: "same-origin", want: http.MethodPost},
+		{name: "form without field", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "name=abc", fetchSite: "same-origin", want: http.MethodPost},
+		{name: "form typed url", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", fetchSite: "none", want: http.MethodDelete},
+		{name: "form from other site", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", fetchSite: "cross-site", origin: "http://example.com", want: http.MethodPost},
+		{name: "form from same site", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", fetchSite: "same-site", want: http.MethodPost},
+		{name: "form with own origin", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", origin: "http://EXAMPLE.com", want: http.MethodDelete},
+		{name: "form with other origin", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", origin: "https://evil.example", want: http.MethodPost},
+		{name: "form with null origin", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", origin: "null", want: http.MethodPost},
+		{name: "form without origin", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "_method=DELETE", want: http.MethodPost},
+		{name: "json body", method: http.MethodPost, contentType: "application/json", body: `{"_method":"DELETE"}`, want: http.MethodPost},
+		{name: "query on json post", method: http.MethodPost, contentType: "application/json", body: "{}", query: "?_method=DELETE", want: http.MethodPost},
+		{name: "header ignored", method: http.MethodPost, contentType: "application/json", body: "{}", header: "DELETE", want: http.MethodPost},
+		{name: "get never overridden", method: http.MethodGet, header: "DELETE", want: http.MethodGet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/v1/items/1"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-HTTP-Method-Override", tt.header)
+			}
+			if tt.fetchSite != "" {
+				r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("method = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPageRoute(t *testing.T) {
+	setupHTMLRoutes(nova.NewRouter())
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: "/", want: true},
+		{pattern: "/items", want: true},
+		{pattern: "/items/{itemId}/edit", want: true},
+		{pattern: "/theme", want: true},
+		{pattern: "/create", want: true},
+		{pattern: cspReportPath, want: false},
+		{pattern: "/api/v1/items", want: false},
+		{pattern: "/healthz", want: false},
+		{pattern: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isPageRoute(tt.pattern); got != tt.want {
+			t.Errorf("isPageRoute(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
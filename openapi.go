@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// constrainedSchemas are the types whose validation tags are documented in their
+// OpenAPI schema, see schemaConstraints.
+var constrainedSchemas = []any{Item{}, NewItemInput{}, CustomField{}}
+
+// customFieldSchemas are the schemas whose customFields property describes the custom
+// fields of the tenant requesting the specification.
+var customFieldSchemas = []string{"Item", "NewItemInput"}
+
+// serveOpenAPISpec serves the OpenAPI specification of router at path. It replaces
+// router.ServeOpenAPISpec, whose schemas leave out the validation rules of the fields
+// and cannot know the custom fields of the tenant. Like nova, it documents the routes
+// registered before it is called.
+func serveOpenAPISpec(router *nova.Router, path string, config nova.OpenAPIConfig) {
+	spec, err := openAPISpec(router, config)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to build OpenAPI spec: %v", err))
+	}
+	base, err := json.Marshal(spec)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to marshal OpenAPI spec: %v", err))
+	}
+
+	router.Handle(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		// Every request patches its own copy of the specification
+		var spec map[string]any
+		if err := json.Unmarshal(base, &spec); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		fields := customFieldsOf(tenantFrom(r.Context()))
+		for _, name := range customFieldSchemas {
+			if properties, ok := jsonPath(spec, "components", "schemas", name, "properties").(map[string]any); ok {
+				properties["customFields"] = customFieldsSchema(fields, properties["customFields"])
+			}
+		}
+		specJSON, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(specJSON)
+	})
+	slog.Info("OpenAPI specification served", "path", path)
+}
+
+// customFieldsSchema describes the customFields object of items holding the values of
+// fields. It keeps the description of the generated property.
+func customFieldsSchema(fields []CustomField, generated any) map[string]any {
+	properties := make(map[string]any, len(fields))
+	required := []string{}
+	for _, f := range fields {
+		property := map[string]any{"title": f.label()}
+		switch f.Type {
+		case "number":
+			property["type"] = "number"
+			if f.Min != nil {
+				property["minimum"] = *f.Min
+			}
+			if f.Max != nil {
+				property["maximum"] = *f.Max
+			}
+		case "bool":
+			property["type"] = "boolean"
+		case "date":
+			property["type"] = "string"
+			property["format"] = "date"
+		case "enum":
+			property["type"] = "string"
+			property["enum"] = f.Options
+		default:
+			property["type"] = "string"
+			if f.MinLength > 0 {
+				property["minLength"] = f.MinLength
+			}
+			if f.MaxLength > 0 {
+				property["maxLength"] = f.MaxLength
+			}
+			if f.Pattern != "" {
+				property["pattern"] = f.Pattern
+			}
+		}
+		properties[f.Name] = property
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if description := jsonPath(generated, "description"); description != nil {
+		schema["description"] = description
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// openAPISpec generates the specification of router as a JSON document, so keywords
+// nova.SchemaObject has no field for can be added to the schemas of constrainedSchemas.
+func openAPISpec(router *nova.Router, config nova.OpenAPIConfig) (map[string]any, error) {
+	data, err := json.Marshal(nova.GenerateOpenAPISpec(router, config))
+	if err != nil {
+		return nil, err
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	schemas, _ := jsonPath(spec, "components", "schemas").(map[string]any)
+	for _, v := range constrainedSchemas {
+		t := reflect.TypeOf(v)
+		properties, _ := jsonPath(schemas, t.Name(), "properties").(map[string]any)
+		if properties == nil {
+			continue
+		}
+		for _, f := range reflect.VisibleFields(t) {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			property, _ := properties[name].(map[string]any)
+			if property == nil {
+				continue
+			}
+			// The string rules of a string slice apply to its elements, see validateSlice
+			target := property
+			if items, ok := property["items"].(map[string]any); ok && f.Type.Kind() == reflect.Slice {
+				target = items
+			}
+			for keyword, value := range schemaConstraints(f.Tag) {
+				switch keyword {
+				case "minItems", "maxItems", "uniqueItems", "minimum", "maximum":
+					property[keyword] = value
+				default:
+					target[keyword] = value
+				}
+			}
+		}
+	}
+	return spec, nil
+}
+
+// schemaConstraints translates the validation tags checked by validateInput into
+// JSON Schema keywords.
+func schemaConstraints(tag reflect.StructTag) map[string]any {
+	keywords := make(map[string]any)
+	for tagName, keyword := range map[string]string{
+		"minlength": "minLength",
+		"maxlength": "maxLength",
+		"minItems":  "minItems",
+		"maxItems":  "maxItems",
+	} {
+		if n, err := strconv.Atoi(tag.Get(tagName)); err == nil {
+			keywords[keyword] = n
+		}
+	}
+	for tagName, keyword := range map[string]string{"min": "minimum", "max": "maximum"} {
+		if n, err := strconv.ParseFloat(tag.Get(tagName), 64); err == nil {
+			keywords[keyword] = n
+		}
+	}
+	if pattern := tag.Get("pattern"); pattern != "" {
+		keywords["pattern"] = pattern
+	}
+	if enum := tag.Get("enum"); enum != "" {
+		keywords["enum"] = strings.Split(enum, "|")
+	}
+	if format := tag.Get("format"); format != "" {
+		keywords["format"] = format
+	}
+	if tag.Get("uniqueItems") == "true" {
+		keywords["uniqueItems"] = true
+	}
+	return keywords
+}
+
+// jsonPath returns the value at the keys of nested JSON objects, or nil if any is missing.
+func jsonPath(v any, keys ...string) any {
+	for _, key := range keys {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// ProblemResponse is an RFC 9457 "problem details" body used for responses where
+// clients benefit from a machine-readable error, such as rate limiting.
+type ProblemResponse struct {
+	Type   string `json:"type" description:"URI reference identifying the problem type"`
+	Title  string `json:"title" description:"Short, human-readable summary of the problem type"`
+	Status int    `json:"status" description:"HTTP status code"`
+	Code   string `json:"code" description:"Stable machine-readable error code, e.g. rate_limited"`
+	Detail string `json:"detail,omitempty" description:"Human-readable explanation of this occurrence"`
+}
+
+// rateLimit describes a token bucket: Requests tokens are refilled every Duration,
+// and up to Burst tokens can be saved up for short spikes.
+type rateLimit struct {
+	// Requests is the number of requests allowed per Duration.
+	Requests int
+	// Duration is the window in which Requests tokens are refilled.
+	Duration time.Duration
+	// Burst is the bucket capacity. Defaults to Requests.
+	Burst int
+}
+
+// routeRateLimit overrides the default limit for a single route.
+type routeRateLimit struct {
+	// Method is the HTTP method of the route. Empty matches any method.
+	Method string
+	// Pattern is the route pattern as registered with the router, e.g. "/api/v1/items/{itemId}".
+	Pattern string
+	// Limit is the quota applied to requests matching the route. A zero Requests exempts the route.
+	Limit rateLimit
+}
+
+// rateLimitConfig holds configuration for rateLimitMiddleware.
+type rateLimitConfig struct {
+	// Default is the quota for routes without a specific limit. A zero Requests disables it.
+	Default rateLimit
+	// Routes lists per-route quotas. Each route gets its own bucket per client.
+	Routes []routeRateLimit
+	// APIKeyHeader is the header identifying API clients. Defaults to "X-API-Key".
+	APIKeyHeader string
+	// APIKeys are the keys clients may be identified by. Requests with any other key are
+	// limited by client IP, so made-up keys cannot open fresh buckets.
+	APIKeys []string
+	// TrustedProxyCIDRs lists proxies whose X-Forwarded-For header is trusted
+	// when determining the client IP.
+	TrustedProxyCIDRs []string
+	// CleanupInterval specifies how often idle buckets are removed. Defaults to 10 minutes.
+	CleanupInterval time.Duration
+}
+
+// tokenBucket tracks the available tokens of one client for one quota.
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+// rateLimiter holds the buckets of all clients.
+type rateLimiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+// rateDecision is the outcome of taking a token, used to fill the response headers.
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// rateLimitMiddleware enforces token-bucket quotas keyed by API key or client IP, and
+// reports the quota state with RateLimit-* response headers. Requests over quota
+// receive a 429 problem response with a Retry-After header.
+func rateLimitMiddleware(config rateLimitConfig) nova.Middleware {
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = "X-API-Key"
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = 10 * time.Minute
+	}
+
+	var trustedNets []*net.IPNet
+	for _, cidr := range config.TrustedProxyCIDRs {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			slog.Warn("Ignoring invalid trusted proxy CIDR", "cidr", cidr)
+			continue
+		}
+		trustedNets = append(trustedNets, ipNet)
+	}
+
+	apiKeys := make(map[[sha256.Size]byte]bool, len(config.APIKeys))
+	for _, key := range config.APIKeys {
+		if key != "" {
+			apiKeys[sha256.Sum256([]byte(key))] = true
+		}
+	}
+
+	limiter := &rateLimiter{buckets: make(map[string]*tokenBucket), lastCleanup: time.Now()}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, scope := config.Default, "default"
+			for _, rl := range config.Routes {
+				if (rl.Method == "" || rl.Method == r.Method) && matchRoutePattern(rl.Pattern, r.URL.Path) {
+					limit, scope = rl.Limit, rl.Method+" "+rl.Pattern
+					break
+				}
+			}
+			if limit.Requests <= 0 || limit.Duration <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := scope + "|" + rateLimitKey(r, config.APIKeyHeader, apiKeys, trustedNets)
+			d := limiter.take(key, limit, config.CleanupInterval)
+
+			// The limit is the quota of the policy; the burst only shows in Remaining
+			hdr := w.Header()
+			hdr.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			hdr.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+			hdr.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+			hdr.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Duration)))
+
+			if !d.allowed {
+				retry := ceilSeconds(d.retryAfter)
+				loggerFrom(r.Context()).Info("Rate limit exceeded", "scope", scope, "retry_after_seconds", retry)
+				hdr.Set("Retry-After", strconv.Itoa(retry))
+				writeProblem(w, http.StatusTooManyRequests, codeRateLimited,
+					tr(r.Context()).N("error."+codeRateLimited, retry, retry))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take refills the bucket for key and tries to consume one token from it.
+func (l *rateLimiter) take(key string, limit rateLimit, cleanupInterval time.Duration) rateDecision {
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Requests
+	}
+	rate := float64(limit.Requests) / limit.Duration.Seconds()
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Sweep buckets that have been full for a while; they carry no state worth keeping.
+	if now.Sub(l.lastCleanup) > cleanupInterval {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastFill) > cleanupInterval {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), lastFill: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.lastFill).Seconds()*rate)
+	b.lastFill = now
+
+	var d rateDecision
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	d.remaining = int(math.Floor(b.tokens))
+	d.reset = time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second))
+	return d
+}
+
+// rateLimitKey identifies the client of a request, preferring a known API key, given
+// by the SHA-256 sums in apiKeys, and otherwise the client IP.
+// Unknown API keys are ignored.
+func rateLimitKey(r *http.Request, apiKeyHeader string, apiKeys map[[sha256.Size]byte]bool, trustedNets []*net.IPNet) string {
+	if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+		// Only hashes are compared and kept, so raw credentials never become map keys
+		if sum := sha256.Sum256([]byte(apiKey)); apiKeys[sum] {
+			return "key:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return "ip:" + clientIP(r, trustedNets)
+}
+
+// clientIP returns the IP address of the client. If the connection comes from a trusted
+// proxy, X-Forwarded-For is walked from right to left and the first untrusted address
+// is used, so clients cannot spoof their IP by prepending entries to the header.
+func clientIP(r *http.Request, trustedNets []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !ipTrusted(net.ParseIP(remote), trustedNets) {
+		return remote
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !ipTrusted(ip, trustedNets) {
+			return ip.String()
+		}
+		remote = ip.String()
+	}
+	return remote
+}
+
+// ipTrusted reports whether ip belongs to one of the trusted networks.
+func ipTrusted(ip net.IP, trustedNets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRoutePattern reports whether path matches a route pattern such as
+// "/api/v1/items/{itemId}", where each {param} matches exactly one path segment.
+func matchRoutePattern(pattern, path string) bool {
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegs) != len(pathSegs) {
+		return false
+	}
+	for i, seg := range patternSegs {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if pathSegs[i] == "" {
+				return false
+			}
+			continue
+		}
+		if seg != pathSegs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds, as used by Retry-After and RateLimit-Reset.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// writeProblem writes an application/problem+json response with the given status, error code and detail.
+func writeProblem(w http.ResponseWriter, statusCode int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(ProblemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Code:   code,
+		Detail: detail,
+	})
+}
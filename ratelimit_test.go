@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		trusted   []*net.IPNet
+		want      string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted proxy ignored", remote: "203.0.113.7:5000", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "no trusted proxies", remote: "10.0.0.1:5000", forwarded: "198.51.100.1", want: "10.0.0.1"},
+		{name: "trusted proxy", remote: "10.0.0.1:5000", forwarded: "198.51.100.1", trusted: trusted, want: "198.51.100.1"},
+		{name: "spoofed entries left of the client", remote: "10.0.0.1:5000", forwarded: "1.2.3.4, 198.51.100.1", trusted: trusted, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", remote: "10.0.0.1:5000", forwarded: "198.51.100.1, 10.0.0.2, 10.0.0.3", trusted: trusted, want: "198.51.100.1"},
+		{name: "only trusted hops", remote: "10.0.0.1:5000", forwarded: "10.0.0.2", trusted: trusted, want: "10.0.0.2"},
+		{name: "garbage stops the walk", remote: "10.0.0.1:5000", forwarded: "198.51.100.1, nonsense", trusted: trusted, want: "10.0.0.1"},
+		{name: "empty header", remote: "10.0.0.1:5000", trusted: trusted, want: "10.0.0.1"},
+		{name: "ipv6", remote: "[2001:db8::1]:5000", want: "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	known := map[[sha256.Size]byte]bool{sha256.Sum256([]byte("known-key")): true}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		want   string
+	}{
+		{name: "no key", want: "ip:203.0.113.7"},
+		{name: "unknown key", apiKey: "made-up", want: "ip:203.0.113.7"},
+		{name: "known key", apiKey: "known-key", want: "key:" + fmt.Sprintf("%x", sha256.Sum256([]byte("known-key")))[:16]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "203.0.113.7:5000"
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if got := rateLimitKey(r, "X-API-Key", known, nil); got != tt.want {
+				t.Errorf("rateLimitKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresUnknownAPIKeys(t *testing.T) {
+	mw := rateLimitMiddleware(rateLimitConfig{
+		Default: rateLimit{Requests: 2, Duration: time.Minute},
+		APIKeys: []string{"known-key"},
+	})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	status := func(apiKey string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/items", nil)
+		r.RemoteAddr = "203.0.113.7:5000"
+		r.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A fresh made-up key on every request still drains the bucket of the client IP
+	for i := range 2 {
+		if code := status(fmt.Sprintf("random-%d", i)); code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, code)
+		}
+	}
+	if code := status("random-2"); code != http.StatusTooManyRequests {
+		t.Errorf("third request with a made-up key: status = %d, want 429", code)
+	}
+	if code := status("known-key"); code != http.StatusOK {
+		t.Errorf("known key: status = %d, want 200 from its own bucket", code)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	mw := rateLimitMiddleware(rateLimitConfig{
+		Default: rateLimit{Requests: 10, Duration: time.Minute, Burst: 5},
+	})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/items", nil))
+	want := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "4",
+		"RateLimit-Policy":    "10;w=60",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
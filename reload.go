@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// restartKeys are settings that are only read at startup. Changing them in a reload
+// is reported but only takes effect after the process is restarted.
+var restartKeys = []string{"host", "port", "watch", "extensions", "shutdown_timeout"}
+
+// configChange is a setting whose value differs between two configurations.
+type configChange struct {
+	key      string
+	old, new string
+}
+
+// liveConfig holds the active configuration and the middleware stack built from it.
+// A reload swaps both atomically: nova composes the global chain for every request,
+// so in-flight requests finish under the stack they started with while new requests
+// pick up the new one.
+type liveConfig struct {
+	// mu serializes reloads; requests never take it.
+	mu      sync.Mutex
+	current atomic.Pointer[loadedConfig]
+	stack   atomic.Pointer[[]nova.Middleware]
+	// limiter is kept across reloads that leave the rate limit settings unchanged,
+	// so clients do not get a fresh quota whenever the configuration is reloaded.
+	limiter nova.Middleware
+	load    func() (*loadedConfig, error)
+}
+
+// newLiveConfig activates cfg. load is called on every reload to read the configuration again.
+func newLiveConfig(cfg *loadedConfig, load func() (*loadedConfig, error)) *liveConfig {
+	l := &liveConfig{load: load, limiter: appRateLimiter(cfg.RateLimit)}
+	stack := middlewareStack(cfg.appConfig, l.limiter)
+	l.current.Store(cfg)
+	l.stack.Store(&stack)
+	return l
+}
+
+// config returns the active configuration.
+func (l *liveConfig) config() *loadedConfig {
+	return l.current.Load()
+}
+
+// middleware runs the active middleware stack. It is the only middleware
+// registered on the router, so that the whole stack can be replaced on reload.
+func (l *liveConfig) middleware(next http.Handler) http.Handler {
+	return chainMiddlewares(next, *l.stack.Load())
+}
+
+// reload reads the configuration again and activates it. An invalid configuration
+// is rejected as a whole and the previous one stays active.
+func (l *liveConfig) reload(trigger string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next, err := l.load()
+	if err != nil {
+		slog.Error("Configuration reload failed, keeping the previous configuration", "trigger", trigger, "error", err)
+		return err
+	}
+
+	prev := l.current.Load()
+	changes := diffConfig(prev, next)
+	if len(changes) == 0 {
+		slog.Info("Configuration reloaded without changes", "trigger", trigger)
+		return nil
+	}
+
+	if !reflect.DeepEqual(prev.RateLimit, next.RateLimit) {
+		l.limiter = appRateLimiter(next.RateLimit)
+	}
+	stack := middlewareStack(next.appConfig, l.limiter)
+	l.stack.Store(&stack)
+	l.current.Store(next)
+	if prev.LogFormat != next.LogFormat || prev.LogLevel != next.LogLevel {
+		configureSlog(next.LogFormat, next.LogLevel)
+	}
+
+	for _, c := range changes {
+		attrs := []any{"key", c.key, "old", c.old, "new", c.new, "source", next.sources[c.key]}
+		if slices.Contains(restartKeys, c.key) {
+			slog.Warn("Configuration changed, restart required to apply", attrs...)
+			continue
+		}
+		slog.Info("Configuration changed", attrs...)
+	}
+	slog.Info("Configuration reloaded", "trigger", trigger, "changes", len(changes))
+	return nil
+}
+
+// watch reloads the configuration on SIGHUP and, when a config file is used, whenever
+// the file's size or modification time changes, checking every interval. It returns
+// when ctx is done.
+func (l *liveConfig) watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	path := l.config().path
+	lastStat := statConfigFile(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = l.reload("SIGHUP")
+		case <-ticker.C:
+			if path == "" {
+				continue
+			}
+			stat := statConfigFile(path)
+			if stat == lastStat {
+				continue
+			}
+			lastStat = stat
+			_ = l.reload("file change")
+		}
+	}
+}
+
+// statConfigFile returns a fingerprint of the file's size and modification time,
+// or "" if the file cannot be read.
+func statConfigFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// diffConfig lists the settings whose values differ, in declaration order.
+// Secrets are reported as changed without revealing either value.
+func diffConfig(prev, next *loadedConfig) []configChange {
+	prevFields, nextFields := prev.fields(), next.fields()
+	var changes []configChange
+	for i, f := range nextFields {
+		before, after := prevFields[i].value.Interface(), f.value.Interface()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		c := configChange{key: f.key, old: formatConfigValue(before), new: formatConfigValue(after)}
+		if isSecretKey(f.key) {
+			c.old, c.new = "<redacted>", "<redacted>"
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// isSecretKey reports whether the setting key holds credentials that must not be logged.
+func isSecretKey(key string) bool {
+	return strings.HasSuffix(key, "secret") || strings.HasSuffix(key, "api_keys")
+}
+
+// formatConfigValue renders a setting for the reload log.
+func formatConfigValue(v any) string {
+	switch v := v.(type) {
+	case []string:
+		return "[" + strings.Join(v, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
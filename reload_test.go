@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *appConfig)
+		want   []configChange
+	}{
+		{name: "unchanged", change: func(c *appConfig) {}},
+		{name: "port", change: func(c *appConfig) { c.Port = 9090 }, want: []configChange{{key: "port", old: "8080", new: "9090"}}},
+		{name: "duration", change: func(c *appConfig) { c.ShutdownTimeout = time.Minute }, want: []configChange{{key: "shutdown_timeout", old: "30s", new: "1m0s"}}},
+		{name: "list", change: func(c *appConfig) { c.Tenant.Allowed = []string{"acme", "globex"} }, want: []configChange{{key: "tenant.allowed", old: "[]", new: "[acme, globex]"}}},
+		{name: "nested group", change: func(c *appConfig) { c.Pages.Security.Enabled = !c.Pages.Security.Enabled }, want: []configChange{{key: "pages.security.enabled", old: "false", new: "true"}}},
+		{name: "tenant secret", change: func(c *appConfig) { c.Tenant.Secret = "s3cret" }, want: []configChange{{key: "tenant.secret", old: "<redacted>", new: "<redacted>"}}},
+		{name: "session secret", change: func(c *appConfig) { c.Session.Secret = "s3cret" }, want: []configChange{{key: "session.secret", old: "<redacted>", new: "<redacted>"}}},
+		{name: "admin secret", change: func(c *appConfig) { c.Admin.Secret = "s3cret" }, want: []configChange{{key: "admin.secret", old: "<redacted>", new: "<redacted>"}}},
+		{name: "api keys", change: func(c *appConfig) { c.RateLimit.APIKeys = []string{"key-1"} }, want: []configChange{{key: "rate_limit.api_keys", old: "<redacted>", new: "<redacted>"}}},
+		{name: "several in field order", change: func(c *appConfig) { c.LogLevel = "debug"; c.Port = 9090 }, want: []configChange{
+			{key: "port", old: "8080", new: "9090"},
+			{key: "log_level", old: "info", new: "debug"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := func() *loadedConfig {
+				c := defaultConfig()
+				c.Port, c.LogLevel, c.ShutdownTimeout = 8080, "info", 30*time.Second
+				return &loadedConfig{appConfig: c}
+			}
+			prev, next := base(), base()
+			tt.change(next.appConfig)
+			if got := diffConfig(prev, next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"tenant.secret":              true,
+		"session.secret":             true,
+		"admin.secret":               true,
+		"rate_limit.api_keys":        true,
+		"tenant.header":              false,
+		"rate_limit.trusted_proxies": false,
+		"port":                       false,
+	} {
+		if got := isSecretKey(key); got != want {
+			t.Errorf("isSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// routeTable resolves request paths back to the route patterns registered with the router,
+// so metrics and logs can use "/api/v1/items/{itemId}" instead of unbounded raw paths.
+type routeTable struct {
+	mu sync.RWMutex
+	// patterns holds the known route patterns, most specific first.
+	patterns []string
+}
+
+// knownRoutes holds the route patterns of the application router.
+// It is loaded once all routes have been registered in setupRoutes.
+var knownRoutes = &routeTable{}
+
+// routeTag is a set of route patterns tagged when they are registered, so middlewares
+// can tell which group a matched route belongs to.
+type routeTag struct {
+	mu       sync.RWMutex
+	patterns map[string]bool
+}
+
+// pageRoutes tags the routes of the HTML pages registered in setupHTMLRoutes.
+var pageRoutes = &routeTag{}
+
+// add tags pattern and returns it, for use around the pattern passed to the router.
+func (t *routeTag) add(pattern string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.patterns == nil {
+		t.patterns = make(map[string]bool)
+	}
+	t.patterns[pattern] = true
+	return pattern
+}
+
+// has reports whether pattern is tagged.
+func (t *routeTag) has(pattern string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.patterns[pattern]
+}
+
+// load replaces the known patterns with the ones registered on router.
+// Nova does not expose its route list, but every registered route appears
+// in the generated OpenAPI paths, which are keyed by pattern.
+func (t *routeTable) load(router *nova.Router) {
+	spec := nova.GenerateOpenAPISpec(router, nova.OpenAPIConfig{})
+	patterns := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		patterns = append(patterns, p)
+	}
+	// Literal segments are more specific than parameters, so patterns with
+	// fewer parameters are tried first ("/items/new" wins over "/items/{itemId}").
+	sort.Slice(patterns, func(i, j int) bool {
+		pi, pj := strings.Count(patterns[i], "{"), strings.Count(patterns[j], "{")
+		if pi != pj {
+			return pi < pj
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	t.mu.Lock()
+	t.patterns = patterns
+	t.mu.Unlock()
+}
+
+// lookup returns the route pattern matching path, or "" if none does.
+// A trailing parameter may also match several segments, which covers
+// catch-all routes such as the static file server ("/static/{filepath}").
+func (t *routeTable) lookup(path string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, p := range t.patterns {
+		if matchRoutePattern(p, path) {
+			return p
+		}
+	}
+	for _, p := range t.patterns {
+		if prefix, ok := strings.CutSuffix(p, "}"); ok {
+			prefix = prefix[:strings.LastIndex(prefix, "{")]
+			if strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+				return p
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Exit codes reported by the process, so supervisors can tell a clean stop
+// from one that lost in-flight work.
+const (
+	// exitOK means the server drained all requests and flushed all pending work.
+	exitOK = 0
+	// exitFailure means the server could not start or failed while running.
+	exitFailure = 1
+	// exitDrainTimeout means in-flight requests were cut off when the shutdown timeout expired.
+	exitDrainTimeout = 3
+	// exitFlushFailed means at least one shutdown hook failed to flush its pending work.
+	exitFlushFailed = 4
+)
+
+// hookGracePeriod bounds the shutdown hooks after a second signal.
+const hookGracePeriod = 2 * time.Second
+
+// exitError carries the process exit code alongside the error that caused it.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// shutdownHook flushes the pending work of a component once no more requests are served.
+type shutdownHook struct {
+	name  string
+	flush func(ctx context.Context) error
+}
+
+// drainCtx is cancelled when the server starts draining, so long-lived responses
+// such as the dev reload stream end instead of holding up the shutdown.
+var drainCtx, startDrain = context.WithCancel(context.Background())
+
+var (
+	// shutdownHooks are run in reverse registration order during shutdown.
+	shutdownHooks []shutdownHook
+	// hooksMu protects shutdownHooks.
+	hooksMu sync.Mutex
+)
+
+// onShutdown registers a hook that runs after the server stopped serving requests and
+// before the process exits, such as stopping the config watcher. The item store lives in
+// memory and nothing is delivered in the background, so no hook has data to flush yet;
+// components that buffer work must register one, and a failing hook exits with exitFlushFailed.
+func onShutdown(name string, flush func(ctx context.Context) error) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	shutdownHooks = append(shutdownHooks, shutdownHook{name: name, flush: flush})
+}
+
+// runShutdownHooks runs all hooks in reverse registration order, so components shut down
+// before the components they depend on, and returns the names of the hooks that failed.
+func runShutdownHooks(ctx context.Context) []string {
+	hooksMu.Lock()
+	hooks := append([]shutdownHook(nil), shutdownHooks...)
+	hooksMu.Unlock()
+
+	var failed []string
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.flush(ctx); err != nil {
+			slog.Error("Shutdown hook failed", "hook", h.name, "error", err)
+			failed = append(failed, h.name)
+			continue
+		}
+		slog.Info("Shutdown hook completed", "hook", h.name)
+	}
+	return failed
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM and then shuts it down gracefully:
+// readiness flips to false, the listener is closed, in-flight requests are drained and the
+// shutdown hooks run, together within cfg.ShutdownTimeout. A second signal closes all
+// connections immediately and leaves the hooks hookGracePeriod at most.
+func serve(cfg *appConfig, handler http.Handler) error {
+	shutdownTimeout := cfg.ShutdownTimeout
+	host, port := cfg.Host, cfg.Port
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+
+	// Under the dev supervisor the listening socket is inherited, so restarts never refuse connections
+	ln, err := inheritedListener()
+	if err != nil {
+		return &exitError{exitFailure, fmt.Errorf("failed to use inherited listener: %w", err)}
+	}
+	if ln == nil {
+		ln, err = net.Listen("tcp", addr)
+		if err != nil {
+			return &exitError{exitFailure, fmt.Errorf("failed to listen on %s: %w", addr, err)}
+		}
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	server.RegisterOnShutdown(startDrain)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	appHealth.setPhase(phaseServing)
+	notifyReady()
+	slog.Info("Starting server", "host", host, "port", port, "shutdownTimeout", shutdownTimeout)
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		appHealth.setPhase(phaseDraining)
+		return &exitError{exitFailure, fmt.Errorf("web server error: %w", err)}
+	case sig := <-sigCh:
+		slog.Info("Received termination signal, draining connections", "signal", sig, "timeout", shutdownTimeout)
+	}
+
+	appHealth.setPhase(phaseDraining)
+
+	// The drain and the hooks share one deadline
+	deadline := time.Now().Add(shutdownTimeout)
+	shutdownCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	// A second signal means the operator does not want to wait any longer.
+	interrupted := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigCh:
+			slog.Warn("Received second signal, closing all connections", "signal", sig)
+			close(interrupted)
+			cancel()
+		case <-shutdownCtx.Done():
+		}
+	}()
+
+	code := exitOK
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Drain did not complete, closing remaining connections", "error", err)
+		_ = server.Close()
+		code = exitDrainTimeout
+	} else {
+		slog.Info("All in-flight requests drained")
+	}
+
+	// Hooks get what is left of the deadline, or the grace period after a second signal
+	hookCtx, hookCancel := context.WithDeadline(context.Background(), deadline)
+	defer hookCancel()
+	go func() {
+		select {
+		case <-interrupted:
+			select {
+			case <-time.After(hookGracePeriod):
+				hookCancel()
+			case <-hookCtx.Done():
+			}
+		case <-hookCtx.Done():
+		}
+	}()
+	if failed := runShutdownHooks(hookCtx); len(failed) > 0 && code == exitOK {
+		code = exitFlushFailed
+	}
+
+	switch code {
+	case exitOK:
+		slog.Info("Server stopped cleanly")
+		return nil
+	case exitDrainTimeout:
+		return &exitError{code, fmt.Errorf("shutdown timed out after %v with requests still in flight", shutdownTimeout)}
+	default:
+		return &exitError{code, errors.New("shutdown completed but pending work could not be flushed")}
+	}
+}
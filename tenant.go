@@ -0,0 +1,232 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// contextKey is a private type used for context keys to avoid collisions.
+type contextKey string
+
+const (
+	// tenantKey is the context key used for storing the resolved tenant ID.
+	tenantKey contextKey = "tenant"
+	// defaultTenant is used when a request does not identify a tenant.
+	defaultTenant = "default"
+)
+
+// tenantIDPattern restricts tenant IDs to DNS-label-like identifiers so they can
+// be used safely as subdomains, map keys and log attributes.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// tenantConfig holds configuration for tenantMiddleware.
+// Without a TokenSecret the tenant is taken from the header, then the subdomain, then
+// the default tenant. With one, the claim of the bearer token decides, see resolveTenant.
+type tenantConfig struct {
+	// HeaderName is the request header carrying the tenant ID. Empty disables header resolution.
+	HeaderName string
+	// BaseDomain is the domain under which tenants are served as subdomains
+	// (e.g. "example.com" resolves "acme.example.com" to "acme"). Empty disables subdomain resolution.
+	BaseDomain string
+	// TokenSecret is the HMAC secret used to verify HS256 bearer tokens. When set, every
+	// request to a scoped route needs a valid token. Empty disables token resolution.
+	TokenSecret string
+	// TokenClaim is the claim in the bearer token that holds the tenant ID. Defaults to "tenant".
+	TokenClaim string
+	// AllowedTenants restricts which tenant IDs, including the default tenant, are
+	// accepted. Empty accepts any valid ID.
+	AllowedTenants []string
+	// Scoped reports whether the route pattern serves the data of a tenant. Other routes,
+	// such as the probes and static assets, are served without resolving a tenant, so they
+	// work without a token. Nil scopes every route.
+	Scoped func(pattern string) bool
+}
+
+// tenantMiddleware resolves the tenant for each request and stores it in the request context.
+// Requests naming an invalid or unknown tenant are rejected before reaching any handler,
+// so handlers can rely on tenantFrom always returning an accepted tenant.
+func tenantMiddleware(config tenantConfig) nova.Middleware {
+	if config.TokenClaim == "" {
+		config.TokenClaim = "tenant"
+	}
+	config.BaseDomain = strings.ToLower(strings.Trim(config.BaseDomain, "."))
+
+	allowed := make(map[string]struct{}, len(config.AllowedTenants))
+	for _, t := range config.AllowedTenants {
+		if t = strings.TrimSpace(t); t != "" {
+			allowed[strings.ToLower(t)] = struct{}{}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Scoped != nil && !config.Scoped(knownRoutes.lookup(r.URL.Path)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger := loggerFrom(r.Context())
+			tenant, err := resolveTenant(r, config)
+			if err != nil {
+				logger.Warn("Tenant token rejected", "error", err)
+				te := errMalformedToken
+				errors.As(err, &te)
+				writeJSONError(w, r, te.status(), string(te))
+				return
+			}
+			if !tenantIDPattern.MatchString(tenant) {
+				logger.Info("Invalid tenant ID", "tenant", tenant)
+				writeJSONError(w, r, http.StatusBadRequest, codeInvalidTenant)
+				return
+			}
+			if len(allowed) > 0 {
+				if _, ok := allowed[tenant]; !ok {
+					logger.Warn("Unknown tenant rejected", "tenant", tenant)
+					writeJSONError(w, r, http.StatusNotFound, codeUnknownTenant, tenant)
+					return
+				}
+			}
+
+			addLogAttrs(r.Context(), "tenant", tenant)
+			ctx := context.WithValue(r.Context(), tenantKey, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// resolveTenant determines the tenant ID for a request according to config. Without a
+// TokenSecret the header or subdomain names the tenant. With one, the tenant is the claim
+// of the bearer token, or the default tenant for tokens without the claim; a request
+// without a valid token is rejected, and so is a header or subdomain naming another tenant.
+func resolveTenant(r *http.Request, config tenantConfig) (string, error) {
+	named := namedTenant(r, config)
+	if config.TokenSecret == "" {
+		return cmp.Or(named, defaultTenant), nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", errMissingToken
+	}
+	tenant, err := tenantFromToken(strings.TrimSpace(token), config.TokenSecret, config.TokenClaim)
+	if err != nil {
+		return "", err
+	}
+	tenant = cmp.Or(tenant, defaultTenant)
+	if named != "" && named != tenant {
+		return "", errTenantMismatch
+	}
+	return tenant, nil
+}
+
+// namedTenant returns the tenant named by the header or, failing that, the subdomain
+// of r, or "" if neither names one.
+func namedTenant(r *http.Request, config tenantConfig) string {
+	if config.HeaderName != "" {
+		if tenant := strings.TrimSpace(r.Header.Get(config.HeaderName)); tenant != "" {
+			return strings.ToLower(tenant)
+		}
+	}
+
+	if config.BaseDomain != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if sub, ok := strings.CutSuffix(host, "."+config.BaseDomain); ok && sub != "" && !strings.Contains(sub, ".") {
+			return sub
+		}
+	}
+	return ""
+}
+
+// tokenError rejects a bearer token. Its value is the API error code sent to the client.
+type tokenError string
+
+const (
+	errMissingToken              tokenError = codeMissingToken
+	errTenantMismatch            tokenError = codeTenantMismatch
+	errMalformedToken            tokenError = codeMalformedToken
+	errUnsupportedTokenAlgorithm tokenError = codeUnsupportedTokenAlgorithm
+	errInvalidTokenSignature     tokenError = codeInvalidTokenSignature
+	errExpiredToken              tokenError = codeExpiredToken
+)
+
+// Error describes the error in English, for logs.
+func (e tokenError) Error() string {
+	return appLocales[fallbackLocale].T("error." + string(e))
+}
+
+// status is the HTTP status of the response rejecting the request. A valid token for
+// another tenant is forbidden rather than unauthenticated.
+func (e tokenError) status() int {
+	if e == errTenantMismatch {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// tenantFromToken verifies an HS256 JWT and returns the value of the tenant claim.
+// It returns an empty string without error if the token is valid but carries no tenant claim.
+func tenantFromToken(token, secret, claim string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errMalformedToken
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", errMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil || header.Alg != "HS256" {
+		return "", errUnsupportedTokenAlgorithm
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || subtle.ConstantTimeCompare(signature, mac.Sum(nil)) != 1 {
+		return "", errInvalidTokenSignature
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errMalformedToken
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return "", errMalformedToken
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() >= int64(exp) {
+		return "", errExpiredToken
+	}
+
+	tenant, _ := claims[claim].(string)
+	return strings.ToLower(strings.TrimSpace(tenant)), nil
+}
+
+// tenantFrom returns the tenant ID stored in ctx by tenantMiddleware,
+// falling back to the default tenant when the middleware is not installed.
+func tenantFrom(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey).(string); ok {
+		return tenant
+	}
+	return defaultTenant
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signToken returns an HS256 JWT carrying claims, signed with secret.
+func signToken(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestResolveTenant(t *testing.T) {
+	const secret = "s3cret"
+	acme := signToken(t, secret, map[string]any{"tenant": "Acme"})
+	noClaim := signToken(t, secret, map[string]any{"sub": "someone"})
+	expired := signToken(t, secret, map[string]any{"tenant": "acme", "exp": time.Now().Add(-time.Minute).Unix()})
+	forged := signToken(t, "other", map[string]any{"tenant": "acme"})
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"tenant":"acme"}`)) + "."
+
+	open := tenantConfig{HeaderName: "X-Tenant-ID", BaseDomain: "example.com"}
+	tokens := tenantConfig{HeaderName: "X-Tenant-ID", BaseDomain: "example.com", TokenSecret: secret, TokenClaim: "tenant"}
+
+	tests := []struct {
+		name    string
+		config  tenantConfig
+		host    string
+		header  string
+		auth    string
+		want    string
+		wantErr error
+	}{
+		{name: "default", config: open, want: defaultTenant},
+		{name: "header", config: open, header: " Globex ", want: "globex"},
+		{name: "subdomain", config: open, host: "acme.example.com:8080", want: "acme"},
+		{name: "header before subdomain", config: open, host: "acme.example.com", header: "globex", want: "globex"},
+		{name: "nested subdomain ignored", config: open, host: "a.b.example.com", want: defaultTenant},
+		{name: "other domain ignored", config: open, host: "acme.example.org", want: defaultTenant},
+		{name: "token ignored without secret", config: open, auth: "Bearer " + forged, want: defaultTenant},
+
+		{name: "token claim", config: tokens, auth: "Bearer " + acme, want: "acme"},
+		{name: "token without claim", config: tokens, auth: "Bearer " + noClaim, want: defaultTenant},
+		{name: "matching header", config: tokens, header: "ACME", auth: "Bearer " + acme, want: "acme"},
+		{name: "matching subdomain", config: tokens, host: "acme.example.com", auth: "Bearer " + acme, want: "acme"},
+		{name: "missing token", config: tokens, wantErr: errMissingToken},
+		{name: "header without token", config: tokens, header: "victim", wantErr: errMissingToken},
+		{name: "subdomain without token", config: tokens, host: "victim.example.com", wantErr: errMissingToken},
+		{name: "basic auth", config: tokens, auth: "Basic YTpi", wantErr: errMissingToken},
+		{name: "other header", config: tokens, header: "victim", auth: "Bearer " + acme, wantErr: errTenantMismatch},
+		{name: "other subdomain", config: tokens, host: "victim.example.com", auth: "Bearer " + acme, wantErr: errTenantMismatch},
+		{name: "header without claim", config: tokens, header: "victim", auth: "Bearer " + noClaim, wantErr: errTenantMismatch},
+		{name: "forged signature", config: tokens, auth: "Bearer " + forged, wantErr: errInvalidTokenSignature},
+		{name: "alg none", config: tokens, auth: "Bearer " + none, wantErr: errUnsupportedTokenAlgorithm},
+		{name: "expired", config: tokens, auth: "Bearer " + expired, wantErr: errExpiredToken},
+		{name: "malformed", config: tokens, auth: "Bearer abc.def", wantErr: errMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/items", nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Tenant-ID", tt.header)
+			}
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			got, err := resolveTenant(r, tt.config)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveTenant() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveTenant() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	knownRoutes.patterns = []string{"/healthz", "/api/v1/items"}
+	t.Cleanup(func() { knownRoutes.patterns = nil })
+
+	mw := tenantMiddleware(tenantConfig{
+		HeaderName:     "X-Tenant-ID",
+		TokenSecret:    "s3cret",
+		AllowedTenants: []string{"acme"},
+		Scoped:         func(pattern string) bool { return strings.HasPrefix(pattern, "/api/") },
+	})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(tenantFrom(r.Context())))
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		claims   map[string]any
+		wantCode int
+		wantBody string
+	}{
+		{name: "unscoped route needs no token", path: "/healthz", header: "victim", wantCode: http.StatusOK, wantBody: defaultTenant},
+		{name: "missing token", path: "/api/v1/items", header: "acme", wantCode: http.StatusUnauthorized},
+		{name: "allowed tenant", path: "/api/v1/items", claims: map[string]any{"tenant": "acme"}, wantCode: http.StatusOK, wantBody: "acme"},
+		{name: "mismatch", path: "/api/v1/items", header: "victim", claims: map[string]any{"tenant": "acme"}, wantCode: http.StatusForbidden},
+		{name: "unknown tenant", path: "/api/v1/items", claims: map[string]any{"tenant": "globex"}, wantCode: http.StatusNotFound},
+		{name: "default tenant not allowed", path: "/api/v1/items", claims: map[string]any{}, wantCode: http.StatusNotFound},
+		{name: "invalid tenant", path: "/api/v1/items", claims: map[string]any{"tenant": "../x"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Tenant-ID", tt.header)
+			}
+			if tt.claims != nil {
+				r.Header.Set("Authorization", "Bearer "+signToken(t, "s3cret", tt.claims))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("tenant = %q, want %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestReadStoreDoesNotCreateStores(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	before := len(stores)
+	if store := readStore("made-up-tenant"); len(store.items) != 0 || len(store.fields) != 0 {
+		t.Fatalf("readStore of an unknown tenant = %+v, want an empty store", store)
+	}
+	if len(stores) != before {
+		t.Errorf("readStore created a store: %d stores, want %d", len(stores), before)
+	}
+}
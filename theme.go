@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// themeCookie remembers the theme a visitor picked.
+const themeCookie = "nova_theme"
+
+// themeAuto follows the browser's prefers-color-scheme: dark, or light when the browser prefers it.
+const themeAuto = "auto"
+
+// themesKey stores the *themeSet of the active configuration in the request context.
+const themesKey contextKey = "themes"
+
+// themeTokens are the CSS custom properties every theme defines, in output order.
+var themeTokens = []string{
+	"primary-color",
+	"primary-light",
+	"secondary-color",
+	"bg-color",
+	"bg-gradient-end",
+	"text-color",
+	"heading-color",
+	"subtle-text",
+	"subtle-bg",
+	"border-color",
+	"header-bg",
+	"accent-bg",
+	"muted-bg",
+	"focus-ring",
+	"danger-color",
+	"danger-bg",
+	"success-color",
+	"success-bg",
+	"card-shadow",
+	"button-shadow",
+	"code-bg",
+}
+
+// themeNamePattern restricts theme names to what is safe in a cookie and a CSS selector.
+var themeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// theme is a named set of values for themeTokens.
+type theme struct {
+	Name   string
+	Label  string
+	Tokens map[string]string
+}
+
+// builtinThemes are always available; the first one is the default of the auto theme.
+var builtinThemes = []theme{
+	{Name: "dark", Label: "Dark", Tokens: map[string]string{
+		"primary-color":   "#f9a825",
+		"primary-light":   "#ffcc66",
+		"secondary-color": "#87ceeb",
+		"bg-color":        "#0a0f2a",
+		"bg-gradient-end": "#2a1a40",
+		"text-color":      "#f0e6d2",
+		"heading-color":   "#ffffff",
+		"subtle-text":     "#cccccc",
+		"subtle-bg":       "#101535",
+		"border-color":    "#333858",
+		"header-bg":       "rgba(10, 15, 42, 0.8)",
+		"accent-bg":       "rgba(249, 168, 37, 0.1)",
+		"muted-bg":        "rgba(204, 204, 204, 0.1)",
+		"focus-ring":      "rgba(249, 168, 37, 0.3)",
+		"danger-color":    "#ff6b6b",
+		"danger-bg":       "rgba(255, 107, 107, 0.1)",
+		"success-color":   "#81c784",
+		"success-bg":      "rgba(102, 187, 106, 0.15)",
+		"card-shadow":     "0 4px 15px rgba(0, 0, 0, 0.4)",
+		"button-shadow":   "0 2px 5px rgba(0, 0, 0, 0.3)",
+		"code-bg":         "#1e222c",
+	}},
+	{Name: "light", Label: "Light", Tokens: map[string]string{
+		"primary-color":   "#b86e00",
+		"primary-light":   "#d98c00",
+		"secondary-color": "#1f6f99",
+		"bg-color":        "#f7f7fb",
+		"bg-gradient-end": "#ece6f5",
+		"text-color":      "#1f2333",
+		"heading-color":   "#0a0f2a",
+		"subtle-text":     "#555a70",
+		"subtle-bg":       "#ffffff",
+		"border-color":    "#d5d7e3",
+		"header-bg":       "rgba(255, 255, 255, 0.85)",
+		"accent-bg":       "rgba(184, 110, 0, 0.1)",
+		"muted-bg":        "rgba(85, 90, 112, 0.1)",
+		"focus-ring":      "rgba(184, 110, 0, 0.3)",
+		"danger-color":    "#c62828",
+		"danger-bg":       "rgba(198, 40, 40, 0.08)",
+		"success-color":   "#2e7d32",
+		"success-bg":      "rgba(46, 125, 50, 0.12)",
+		"card-shadow":     "0 4px 15px rgba(31, 35, 51, 0.08)",
+		"button-shadow":   "0 2px 5px rgba(31, 35, 51, 0.15)",
+		"code-bg":         "#eef0f6",
+	}},
+	{Name: "high-contrast", Label: "High contrast", Tokens: map[string]string{
+		"primary-color":   "#ffff00",
+		"primary-light":   "#ffff66",
+		"secondary-color": "#00ffff",
+		"bg-color":        "#000000",
+		"bg-gradient-end": "#000000",
+		"text-color":      "#ffffff",
+		"heading-color":   "#ffffff",
+		"subtle-text":     "#ffffff",
+		"subtle-bg":       "#000000",
+		"border-color":    "#ffffff",
+		"header-bg":       "#000000",
+		"accent-bg":       "#333300",
+		"muted-bg":        "#333333",
+		"focus-ring":      "#00ffff",
+		"danger-color":    "#ff6666",
+		"danger-bg":       "#330000",
+		"success-color":   "#66ff66",
+		"success-bg":      "#003300",
+		"card-shadow":     "none",
+		"button-shadow":   "none",
+		"code-bg":         "#000000",
+	}},
+}
+
+// themeSet holds the themes visitors can choose from and the one shown to visitors
+// who have not chosen yet.
+type themeSet struct {
+	themes []theme
+	def    string
+}
+
+// newThemeSet returns the built-in themes plus the custom theme of the configuration, if any.
+// The settings must have passed validateTheme.
+func newThemeSet(settings themeSettings) *themeSet {
+	set := &themeSet{themes: slices.Clone(builtinThemes), def: settings.Default}
+	if custom := settings.Custom; custom.Name != "" {
+		base, _ := set.lookup(custom.Base)
+		t := theme{Name: custom.Name, Label: custom.Label, Tokens: make(map[string]string, len(themeTokens))}
+		if t.Label == "" {
+			t.Label = custom.Name
+		}
+		for token, value := range base.Tokens {
+			t.Tokens[token] = value
+		}
+		for _, kv := range custom.Tokens {
+			token, value, _ := strings.Cut(kv, "=")
+			t.Tokens[strings.TrimSpace(token)] = strings.TrimSpace(value)
+		}
+		set.themes = append(set.themes, t)
+	}
+	return set
+}
+
+// lookup returns the theme with the given name.
+func (s *themeSet) lookup(name string) (theme, bool) {
+	for _, t := range s.themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return theme{}, false
+}
+
+// validateTheme checks the theme settings, reporting each problem through fail.
+func validateTheme(settings themeSettings, fail func(key, format string, args ...any)) {
+	custom := settings.Custom
+	known := []string{themeAuto}
+	for _, t := range builtinThemes {
+		known = append(known, t.Name)
+	}
+
+	if custom.Name != "" {
+		switch {
+		case !themeNamePattern.MatchString(custom.Name):
+			fail("theme.custom.name", "must be lowercase letters, digits and dashes, got %q", custom.Name)
+		case slices.Contains(known, custom.Name):
+			fail("theme.custom.name", "%q is a built-in theme", custom.Name)
+		default:
+			known = append(known, custom.Name)
+		}
+		if !slices.ContainsFunc(builtinThemes, func(t theme) bool { return t.Name == custom.Base }) {
+			fail("theme.custom.base", "must be one of dark, light, high-contrast, got %q", custom.Base)
+		}
+		for _, kv := range custom.Tokens {
+			token, value, ok := strings.Cut(kv, "=")
+			token, value = strings.TrimSpace(token), strings.TrimSpace(value)
+			switch {
+			case !ok || value == "":
+				fail("theme.custom.tokens", "%q must have the form token=value", kv)
+			case !slices.Contains(themeTokens, token):
+				fail("theme.custom.tokens", "unknown token %q, expected one of %s", token, strings.Join(themeTokens, ", "))
+			case strings.ContainsAny(value, ";{}<>\\"):
+				fail("theme.custom.tokens", "value of %q must not contain ; { } < > or \\", token)
+			}
+		}
+	} else if custom.Label != "" || len(custom.Tokens) > 0 {
+		fail("theme.custom.name", "must be set to register the custom theme")
+	}
+
+	if !slices.Contains(known, settings.Default) {
+		fail("theme.default", "must be one of %s, got %q", strings.Join(known, ", "), settings.Default)
+	}
+}
+
+// themeMiddleware makes the themes of the configuration available to the HTML pages.
+func themeMiddleware(settings themeSettings) nova.Middleware {
+	set := newThemeSet(settings)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), themesKey, set)))
+		})
+	}
+}
+
+// themesFrom returns the themes of the request, or the built-in ones outside of a request.
+func themesFrom(ctx context.Context) *themeSet {
+	if set, ok := ctx.Value(themesKey).(*themeSet); ok {
+		return set
+	}
+	return &themeSet{themes: builtinThemes, def: themeAuto}
+}
+
+// selectedTheme returns the theme the visitor picked, falling back to the configured
+// default when the cookie is missing or names a theme that no longer exists.
+func selectedTheme(r *http.Request) string {
+	set := themesFrom(r.Context())
+	if cookie, err := r.Cookie(themeCookie); err == nil {
+		if _, ok := set.lookup(cookie.Value); ok || cookie.Value == themeAuto {
+			return cookie.Value
+		}
+	}
+	return set.def
+}
+
+// themeRootStyles returns the stylesheet defining the theme tokens: the dark theme,
+// replaced by the light theme when the browser prefers a light color scheme.
+func themeRootStyles() string {
+	dark, light := builtinThemes[0], builtinThemes[1]
+	return ":root {\n" + themeDeclarations(dark, "\t") + "\t--border-anim-speed: 2s;\n}\n\n" +
+		"@media (prefers-color-scheme: light) {\n\t:root {\n" + themeDeclarations(light, "\t\t") + "\t}\n}\n"
+}
+
+// themeDeclarations renders the custom properties of t, one per line.
+func themeDeclarations(t theme, indent string) string {
+	var b strings.Builder
+	for _, token := range themeTokens {
+		fmt.Fprintf(&b, "%s--%s: %s;\n", indent, token, t.Tokens[token])
+	}
+	return b.String()
+}
+
+// themeHeadExtras returns the style that applies the visitor's theme. It comes after the
+// theme stylesheet, so it overrides the prefers-color-scheme rules; the auto theme adds nothing.
+func themeHeadExtras(r *http.Request) []nova.HTMLElement {
+	t, ok := themesFrom(r.Context()).lookup(selectedTheme(r))
+	if !ok {
+		return nil
+	}
+	return []nova.HTMLElement{nova.StyleTag(":root {\n" + themeDeclarations(t, "\t") + "}")}
+}
+
+// themeSwitcher renders the form that changes the theme. It works without JavaScript
+// and returns to the page at self. Built-in themes are labelled in the visitor's
+// language; the custom theme keeps its configured label.
+func themeSwitcher(r *http.Request, self *url.URL) nova.HTMLElement {
+	l := tr(r.Context())
+	current := selectedTheme(r)
+	options := []nova.HTMLElement{selectOption(themeAuto, l.T("theme.name.auto"), current == themeAuto)}
+	for _, t := range themesFrom(r.Context()).themes {
+		options = append(options, selectOption(t.Name, l.TOr("theme.name."+t.Name, t.Label), current == t.Name))
+	}
+	return nova.Form(
+		nova.Label().Text(l.T("theme.label")).Attr("for", "theme"),
+		nova.Select(options...).Attr("name", "theme").ID("theme"),
+		nova.HiddenInput("return", self.RequestURI()),
+		nova.SubmitButton(l.T("theme.apply")).Class("btn btn-secondary"),
+	).Attr("method", "POST").Attr("action", "/theme").Class("theme-switcher")
+}
+
+// handleSetTheme remembers the chosen theme for a year and returns to the page the form was on.
+func handleSetTheme(rc *nova.ResponseContext) error {
+	r := rc.Request()
+	name := r.PostFormValue("theme")
+	if _, ok := themesFrom(r.Context()).lookup(name); !ok && name != themeAuto {
+		return jsonError(rc, http.StatusBadRequest, codeUnknownTheme, name)
+	}
+
+	http.SetCookie(rc.Writer(), &http.Cookie{
+		Name:     themeCookie,
+		Value:    name,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only return to pages of this site, never to another host
+	target := r.PostFormValue("return")
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		target = "/"
+	}
+	return rc.Redirect(http.StatusSeeOther, target)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlValue is a parsed value together with the line it was defined on,
+// so validation errors can point at the offending line.
+type tomlValue struct {
+	value any
+	line  int
+}
+
+// parseTOML parses the subset of TOML used by configuration files into a flat map
+// of dotted keys. Supported are comments, [table] and [dotted.table] headers, bare
+// and dotted keys, basic and literal strings, integers, floats, booleans and arrays
+// of those (which may span several lines). Inline tables, dates and multi-line
+// strings are not supported and are reported as errors.
+func parseTOML(data string) (map[string]tomlValue, error) {
+	values := make(map[string]tomlValue)
+	table := ""
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", lineNo)
+			}
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			name, err := parseTOMLKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			table = name
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, err := parseTOMLKey(strings.TrimSpace(line[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		raw := strings.TrimSpace(line[eq+1:])
+
+		// Arrays may continue over several lines until the brackets balance.
+		for strings.HasPrefix(raw, "[") && !tomlBracketsBalanced(raw) {
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("line %d: unterminated array", lineNo)
+			}
+			raw += " " + strings.TrimSpace(stripTOMLComment(lines[i]))
+		}
+
+		value, err := parseTOMLValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+		}
+
+		if table != "" {
+			key = table + "." + key
+		}
+		if prev, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: key %q already defined on line %d", lineNo, key, prev.line)
+		}
+		values[key] = tomlValue{value: value, line: lineNo}
+	}
+
+	return values, nil
+}
+
+// parseTOMLKey validates a bare or dotted key and returns it in dotted form.
+func parseTOMLKey(key string) (string, error) {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+		for _, r := range part {
+			if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return "", fmt.Errorf("invalid key %q: only bare keys are supported", key)
+			}
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// parseTOMLValue parses a single scalar or array value.
+func parseTOMLValue(raw string) (any, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	case strings.HasPrefix(raw, `"""`), strings.HasPrefix(raw, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(raw, `"`):
+		s, rest, err := parseTOMLBasicString(raw)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("unexpected %q after string", rest)
+		}
+		return s, nil
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		if rest := strings.TrimSpace(raw[end+2:]); rest != "" {
+			return nil, fmt.Errorf("unexpected %q after string", rest)
+		}
+		return raw[1 : end+1], nil
+	case strings.HasPrefix(raw, "["):
+		return parseTOMLArray(raw)
+	case strings.HasPrefix(raw, "{"):
+		return nil, fmt.Errorf("inline tables are not supported")
+	}
+
+	num := strings.ReplaceAll(raw, "_", "")
+	if n, err := strconv.ParseInt(num, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", raw)
+}
+
+// parseTOMLBasicString parses a double-quoted string at the start of raw and
+// returns it together with the trimmed remainder.
+func parseTOMLBasicString(raw string) (string, string, error) {
+	var sb strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		switch c {
+		case '"':
+			return sb.String(), strings.TrimSpace(raw[i+1:]), nil
+		case '\\':
+			i++
+			if i >= len(raw) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			switch raw[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\':
+				sb.WriteByte(raw[i])
+			default:
+				return "", "", fmt.Errorf("unsupported escape sequence \\%c", raw[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// parseTOMLArray parses an array of scalars, allowing a trailing comma.
+func parseTOMLArray(raw string) ([]any, error) {
+	inner := strings.TrimSpace(raw[1:])
+	values := []any{}
+	for {
+		inner = strings.TrimSpace(inner)
+		if strings.HasPrefix(inner, "]") {
+			if rest := strings.TrimSpace(inner[1:]); rest != "" {
+				return nil, fmt.Errorf("unexpected %q after array", rest)
+			}
+			return values, nil
+		}
+		if inner == "" {
+			return nil, fmt.Errorf("unterminated array")
+		}
+
+		if inner[0] == '"' {
+			s, rest, err := parseTOMLBasicString(inner)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+			inner = rest
+		} else if inner[0] == '\'' {
+			end := strings.Index(inner[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			values = append(values, inner[1:end+1])
+			inner = inner[end+2:]
+		} else {
+			end := strings.IndexAny(inner, ",]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated array")
+			}
+			elem := strings.TrimSpace(inner[:end])
+			inner = inner[end:]
+			if strings.HasPrefix(elem, "[") {
+				return nil, fmt.Errorf("nested arrays are not supported")
+			}
+			v, err := parseTOMLValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+
+		inner = strings.TrimSpace(inner)
+		if strings.HasPrefix(inner, ",") {
+			inner = inner[1:]
+		} else if !strings.HasPrefix(inner, "]") {
+			return nil, fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+// stripTOMLComment removes a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	inBasic, inLiteral := false, false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && inBasic:
+			i++
+		case c == '"' && !inLiteral:
+			inBasic = !inBasic
+		case c == '\'' && !inBasic:
+			inLiteral = !inLiteral
+		case c == '#' && !inBasic && !inLiteral:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlBracketsBalanced reports whether every [ in raw outside of strings has been closed.
+func tomlBracketsBalanced(raw string) bool {
+	depth := 0
+	inBasic, inLiteral := false, false
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == '\\' && inBasic:
+			i++
+		case c == '"' && !inLiteral:
+			inBasic = !inBasic
+		case c == '\'' && !inBasic:
+			inLiteral = !inLiteral
+		case c == '[' && !inBasic && !inLiteral:
+			depth++
+		case c == ']' && !inBasic && !inLiteral:
+			depth--
+		}
+	}
+	return depth <= 0
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// formatPatterns are the values of the format tag the inputs use. Unknown formats
+// accept every value.
+var formatPatterns = map[string]*regexp.Regexp{
+	"alpha": regexp.MustCompile(`^[A-Za-z]+$`),
+}
+
+// tagPatterns caches the compiled pattern tags of the input structs by their source,
+// so each is compiled once rather than on every request.
+var tagPatterns sync.Map
+
+// tagPattern returns the compiled pattern tag. Tags are constants of the code, so an
+// invalid one is a programming error and panics.
+func tagPattern(pattern string) *regexp.Regexp {
+	if re, ok := tagPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := tagPatterns.LoadOrStore(pattern, regexp.MustCompile(pattern))
+	return re.(*regexp.Regexp)
+}
+
+// violation is a rejected input field. Rule is the name of the violated tag, or of the
+// format for format checks, and doubles as the error code reported for the field.
+type violation struct {
+	Field string
+	Rule  string
+	// Args are formatted into the message after the field name; an int first argument
+	// is also the count that selects the plural form.
+	Args []any
+}
+
+// maxBodyBytes bounds the size of the request bodies bindValidated reads.
+const maxBodyBytes = 1 << 20
+
+// inputError rejects a request body that is too large, cannot be decoded or violates
+// the validation tags of the input struct.
+type inputError struct {
+	Code       string // codeBodyTooLarge, codeInvalidJSON, codeInvalidForm or codeInvalidInput
+	Cause      error  // decoding error, for logs
+	Violations []violation
+}
+
+// Error describes the error in English, for logs.
+func (e *inputError) Error() string {
+	if e.Cause != nil {
+		return e.Code + ": " + e.Cause.Error()
+	}
+	return e.Message(appLocales[fallbackLocale])
+}
+
+// Status is the HTTP status of the API response rejecting the input.
+func (e *inputError) Status() int {
+	if e.Code == codeBodyTooLarge {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// Message describes the error in the language of l, as shown above the HTML forms.
+func (e *inputError) Message(l *locale) string {
+	if len(e.Violations) == 0 {
+		return l.T("error." + e.Code)
+	}
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.message(l)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Response is the API error body of e with one detail per rejected field.
+func (e *inputError) Response(ctx context.Context) ErrorResponse {
+	l := tr(ctx)
+	resp := errorResponse(ctx, e.Code)
+	for _, v := range e.Violations {
+		resp.Details = append(resp.Details, FieldError{Field: v.Field, Code: v.Rule, Message: v.message(l)})
+	}
+	return resp
+}
+
+// message translates v through the "validation.<rule>" entry of the catalogs.
+func (v violation) message(l *locale) string {
+	n := 0
+	if len(v.Args) > 0 {
+		n, _ = v.Args[0].(int)
+	}
+	return l.N("validation."+v.Rule, n, append([]any{v.Field}, v.Args...)...)
+}
+
+// normalizer is implemented by inputs that clean up their values, e.g. by trimming
+// them, before they are validated.
+type normalizer interface {
+	normalize()
+}
+
+// bindValidated decodes the JSON or form body of the request into v and validates it
+// against its struct tags. It replaces rc.BindValidated, whose English-only or
+// Accept-Language-only messages carry no codes.
+func bindValidated(rc *nova.ResponseContext, v any) *inputError {
+	limitBody(rc.Writer(), rc.Request())
+	if strings.Contains(rc.Request().Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(rc.Request().Body).Decode(v); err != nil {
+			return decodeError(codeInvalidJSON, err)
+		}
+	} else if err := rc.BindForm(v); err != nil {
+		return decodeError(codeInvalidForm, err)
+	}
+	if n, ok := v.(normalizer); ok {
+		n.normalize()
+	}
+	if violations := validateInput(v); len(violations) > 0 {
+		return &inputError{Code: codeInvalidInput, Violations: violations}
+	}
+	return nil
+}
+
+// inputTag returns the tag of the named field of the struct v, so the HTML forms can
+// mirror the validation rules of their input.
+func inputTag(v any, field, tag string) string {
+	f, _ := reflect.TypeOf(v).FieldByName(field)
+	return f.Tag.Get(tag)
+}
+
+// limitBody caps the body of r at maxBodyBytes. Reading past the limit fails with an
+// *http.MaxBytesError, and so does every later read.
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+}
+
+// decodeError rejects a body that could not be decoded, with codeBodyTooLarge if it
+// exceeds maxBodyBytes and with code otherwise.
+func decodeError(code string, err error) *inputError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		code = codeBodyTooLarge
+	}
+	return &inputError{Code: code, Cause: err}
+}
+
+// validateInput checks the exported fields of the struct v points to against the tags
+// nova also documents in the OpenAPI schema, limited to the ones the inputs use: fields
+// are required unless their json tag has omitempty; strings support minlength,
+// maxlength, pattern, enum and the formats in formatPatterns; numbers min; slices
+// maxItems and uniqueItems. The string rules of a string slice apply to each of its
+// elements. Lengths count characters.
+func validateInput(v any) []violation {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var violations []violation
+	for _, f := range reflect.VisibleFields(rv.Type()) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		parts := strings.Split(f.Tag.Get("json"), ",")
+		name := parts[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fv := rv.FieldByIndex(f.Index)
+		if fv.IsZero() {
+			if !slices.Contains(parts[1:], "omitempty") {
+				violations = append(violations, violation{Field: name, Rule: "required"})
+			}
+			continue
+		}
+
+		switch fv.Kind() {
+		case reflect.String:
+			violations = append(violations, validateString(name, fv.String(), f.Tag)...)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			violations = append(violations, validateNumber(name, float64(fv.Int()), f.Tag)...)
+		case reflect.Float32, reflect.Float64:
+			violations = append(violations, validateNumber(name, fv.Float(), f.Tag)...)
+		case reflect.Slice:
+			violations = append(violations, validateSlice(name, fv, f.Tag)...)
+		}
+	}
+	return violations
+}
+
+// validateString applies the string rules of tag to s.
+func validateString(name, s string, tag reflect.StructTag) []violation {
+	var violations []violation
+	length := utf8.RuneCountInString(s)
+	if n, err := strconv.Atoi(tag.Get("minlength")); err == nil && length < n {
+		violations = append(violations, violation{Field: name, Rule: "minlength", Args: []any{n}})
+	}
+	if n, err := strconv.Atoi(tag.Get("maxlength")); err == nil && length > n {
+		violations = append(violations, violation{Field: name, Rule: "maxlength", Args: []any{n}})
+	}
+	if pattern := tag.Get("pattern"); pattern != "" {
+		if !tagPattern(pattern).MatchString(s) {
+			violations = append(violations, violation{Field: name, Rule: "pattern"})
+		}
+	}
+	if enum := tag.Get("enum"); enum != "" {
+		if allowed := strings.Split(enum, "|"); !slices.Contains(allowed, s) {
+			violations = append(violations, violation{Field: name, Rule: "enum", Args: []any{strings.Join(allowed, ", ")}})
+		}
+	}
+	format := tag.Get("format")
+	if re, ok := formatPatterns[format]; ok && !re.MatchString(s) {
+		violations = append(violations, violation{Field: name, Rule: format})
+	}
+	return violations
+}
+
+// validateNumber applies the min rule of tag to n.
+func validateNumber(name string, n float64, tag reflect.StructTag) []violation {
+	if limit, err := strconv.ParseFloat(tag.Get("min"), 64); err == nil && n < limit {
+		return []violation{{Field: name, Rule: "min", Args: []any{tag.Get("min")}}}
+	}
+	return nil
+}
+
+// validateSlice applies the maxItems and uniqueItems rules of tag to the slice fv,
+// and the string rules to every element of a string slice, named like "tags[2]".
+func validateSlice(name string, fv reflect.Value, tag reflect.StructTag) []violation {
+	var violations []violation
+	if n, err := strconv.Atoi(tag.Get("maxItems")); err == nil && fv.Len() > n {
+		violations = append(violations, violation{Field: name, Rule: "maxItems", Args: []any{n}})
+	}
+	if fv.Type().Elem().Kind() == reflect.String {
+		for i := range fv.Len() {
+			violations = append(violations, validateString(fmt.Sprintf("%s[%d]", name, i), fv.Index(i).String(), tag)...)
+		}
+	}
+	if tag.Get("uniqueItems") == "true" {
+		seen := make(map[string]bool, fv.Len())
+		for i := range fv.Len() {
+			key := fmt.Sprint(fv.Index(i).Interface())
+			if seen[key] {
+				violations = append(violations, violation{Field: name, Rule: "uniqueItems"})
+				break
+			}
+			seen[key] = true
+		}
+	}
+	return violations
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+func TestValidateInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input NewItemInput
+		want  []violation
+	}{
+		{name: "valid", input: NewItemInput{Name: "Widget", Tags: []string{"a-b", "c_1"}, Priority: "high"}},
+		{name: "missing name", input: NewItemInput{}, want: []violation{{Field: "name", Rule: "required"}}},
+		{name: "name too short", input: NewItemInput{Name: "ab"}, want: []violation{{Field: "name", Rule: "minlength", Args: []any{3}}}},
+		{name: "name at maximum length", input: NewItemInput{Name: "Abcdefghij"}},
+		{name: "name too long", input: NewItemInput{Name: "Abcdefghijk"}, want: []violation{{Field: "name", Rule: "maxlength", Args: []any{10}}}},
+		{name: "name not alpha", input: NewItemInput{Name: "abc1"}, want: []violation{{Field: "name", Rule: "alpha"}}},
+		{name: "unknown priority", input: NewItemInput{Name: "abc", Priority: "urgent"}, want: []violation{{Field: "priority", Rule: "enum", Args: []any{"low, medium, high"}}}},
+		{name: "tag pattern", input: NewItemInput{Name: "abc", Tags: []string{"ok", "not ok"}}, want: []violation{{Field: "tags[1]", Rule: "pattern"}}},
+		{name: "too many tags", input: NewItemInput{Name: "abc", Tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ",")}, want: []violation{{Field: "tags", Rule: "maxItems", Args: []any{10}}}},
+		{name: "several violations", input: NewItemInput{Name: "a1", Priority: "x"}, want: []violation{
+			{Field: "name", Rule: "minlength", Args: []any{3}},
+			{Field: "name", Rule: "alpha"},
+			{Field: "priority", Rule: "enum", Args: []any{"low, medium, high"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateInput(&tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateInput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindValidated(t *testing.T) {
+	router := nova.NewRouter()
+	router.PostFunc("/items", func(rc *nova.ResponseContext) error {
+		var in NewItemInput
+		if err := bindValidated(rc, &in); err != nil {
+			return rc.JSON(err.Status(), err.Response(rc.Request().Context()))
+		}
+		return rc.JSON(http.StatusOK, in)
+	}, nil)
+
+	oversized := `{"name":"abc","description":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantError   string
+	}{
+		{name: "json", contentType: "application/json", body: `{"name":"abc"}`, wantCode: http.StatusOK},
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: "name=abc", wantCode: http.StatusOK},
+		{name: "invalid json", contentType: "application/json", body: `{"name":`, wantCode: http.StatusBadRequest, wantError: codeInvalidJSON},
+		{name: "invalid input", contentType: "application/json", body: `{"name":"a"}`, wantCode: http.StatusBadRequest, wantError: codeInvalidInput},
+		{name: "oversized json", contentType: "application/json", body: oversized, wantCode: http.StatusRequestEntityTooLarge, wantError: codeBodyTooLarge},
+		{name: "oversized form", contentType: "application/x-www-form-urlencoded", body: "name=abc&description=" + strings.Repeat("x", maxBodyBytes), wantCode: http.StatusRequestEntityTooLarge, wantError: codeBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			methodOverride(router).ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %.200s)", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tt.wantError {
+				t.Errorf("code = %q, want %q", resp.Code, tt.wantError)
+			}
+		})
+	}
+}